@@ -0,0 +1,390 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/db"
+	"github.com/ZecreyGaming/BlockChainWar/game"
+	"github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"github.com/topfreegames/pitaya/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	Prefix = "/admin/"
+
+	defaultList = 100
+
+	shutdownDelay = time.Second
+)
+
+// Server is the http api operators run the game with, every request needs the admin token
+// and every action is written to the audit log
+type Server struct {
+	cfg       *config.Config
+	db        *db.Client
+	app       pitaya.Pitaya
+	arenas    *game.Arenas
+	sdkClient *zecreyface.Client
+	mux       *http.ServeMux
+}
+
+// Response is the body of every answer, Result holds the data or the error
+type Response struct {
+	Code   int         `json:"code"`
+	Result interface{} `json:"result"`
+}
+
+type PlayerRequest struct {
+	PlayerID uint64 `json:"player_id"`
+}
+
+type DurationRequest struct {
+	Seconds int `json:"seconds"`
+}
+
+type StartRequest struct {
+	AccountName string `json:"account_name"` // gets the NFT of the round
+}
+
+type MintRequest struct {
+	GameID      uint   `json:"game_id"`
+	AccountName string `json:"account_name"`
+}
+
+type RetryRequest struct {
+	ID uint `json:"id"`
+}
+
+var (
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("conflict")
+)
+
+func NewServer(cfg *config.Config, db *db.Client, app pitaya.Pitaya, arenas *game.Arenas, sdkClient *zecreyface.Client) *Server {
+	s := &Server{cfg: cfg, db: db, app: app, arenas: arenas, sdkClient: sdkClient, mux: http.NewServeMux()}
+	s.mux.HandleFunc(Prefix+"game-info", s.get(s.gameInfo))
+	s.mux.HandleFunc(Prefix+"round/start", s.action("round.start", s.startRound))
+	s.mux.HandleFunc(Prefix+"round/stop", s.action("round.stop", s.stopRound))
+	s.mux.HandleFunc(Prefix+"round/pause", s.action("round.pause", s.pauseRound))
+	s.mux.HandleFunc(Prefix+"round/resume", s.action("round.resume", s.resumeRound))
+	s.mux.HandleFunc(Prefix+"round/duration", s.action("round.duration", s.setDuration))
+	s.mux.HandleFunc(Prefix+"players/kick", s.action("players.kick", s.kick))
+	s.mux.HandleFunc(Prefix+"players/ban", s.action("players.ban", s.ban(true)))
+	s.mux.HandleFunc(Prefix+"players/unban", s.action("players.unban", s.ban(false)))
+	s.mux.HandleFunc(Prefix+"chat/clear", s.action("chat.clear", s.clearChat))
+	s.mux.HandleFunc(Prefix+"server/shutdown", s.action("server.shutdown", s.shutdown))
+	s.mux.HandleFunc(Prefix+"rewards/mint", s.action("rewards.mint", s.mint))
+	s.mux.HandleFunc(Prefix+"rewards/retry", s.action("rewards.retry", s.retry))
+	s.mux.HandleFunc(Prefix+"rewards", s.get(s.rewards))
+	s.mux.HandleFunc(Prefix+"audit", s.get(s.audit))
+	return s
+}
+
+type operatorKey struct{}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// no token, no admin api
+	if len(s.cfg.AdminTokens) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	operator := ""
+	for t, name := range s.cfg.AdminTokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			operator = name
+		}
+	}
+	if operator == "" {
+		reply(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	s.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), operatorKey{}, operator)))
+}
+
+func reply(w http.ResponseWriter, status int, result interface{}) {
+	code := 0
+	if status != http.StatusOK {
+		code = status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&Response{Code: code, Result: result})
+}
+
+func (s *Server) get(f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			reply(w, http.StatusMethodNotAllowed, "use GET")
+			return
+		}
+		result, err := f(r)
+		if err != nil {
+			reply(w, status(err), err.Error())
+			return
+		}
+		reply(w, http.StatusOK, result)
+	}
+}
+
+// action decodes the json body in params, runs f and writes the audit log
+func (s *Server) action(name string, f func(r *http.Request, params []byte) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			reply(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		var params json.RawMessage
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+				reply(w, http.StatusBadRequest, "invalid json body")
+				return
+			}
+		}
+		result, err := f(r, params)
+		entry := &model.AuditLog{
+			Actor:   r.Context().Value(operatorKey{}).(string),
+			Action:  name,
+			ArenaID: r.URL.Query().Get("arena"),
+			Params:  string(params),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if err := s.db.Audit.Create(entry); err != nil {
+			zap.L().Error("failed to write audit log", zap.String("action", name), zap.Error(err))
+		}
+		if err != nil {
+			reply(w, status(err), err.Error())
+			return
+		}
+		if result == nil {
+			result = "success"
+		}
+		reply(w, http.StatusOK, result)
+	}
+}
+
+func status(err error) int {
+	switch {
+	case errors.Is(err, errNotFound), errors.Is(err, game.ErrNoPlayer):
+		return http.StatusNotFound
+	case errors.Is(err, errConflict), errors.Is(err, game.ErrNotRunning), errors.Is(err, game.ErrRunning):
+		return http.StatusConflict
+	case errors.As(err, new(*badRequest)), errors.Is(err, game.ErrBadDuration):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+type badRequest struct{ msg string }
+
+func (e *badRequest) Error() string { return e.msg }
+
+func decode(params []byte, v interface{}) error {
+	if len(params) == 0 {
+		return &badRequest{"missing json body"}
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &badRequest{err.Error()}
+	}
+	return nil
+}
+
+// arena is picked with ?arena=, the default arena otherwise
+func (s *Server) arena(r *http.Request) (*game.Arena, error) {
+	id := r.URL.Query().Get("arena")
+	if id == "" {
+		return s.arenas.Default(), nil
+	}
+	a, ok := s.arenas.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("arena %s: %w", id, errNotFound)
+	}
+	return a, nil
+}
+
+func (s *Server) gameInfo(r *http.Request) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.Game().GetGameInfo()
+}
+
+func (s *Server) startRound(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	var req StartRequest
+	if len(params) > 0 {
+		if err := decode(params, &req); err != nil {
+			return nil, err
+		}
+	}
+	return nil, a.Game().ForceStart(req.AccountName)
+}
+
+func (s *Server) stopRound(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.Game().ForceStop()
+}
+
+func (s *Server) pauseRound(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.Game().Pause()
+}
+
+func (s *Server) resumeRound(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.Game().Resume()
+}
+
+func (s *Server) setDuration(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	var req DurationRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	return nil, a.Game().SetDuration(req.Seconds)
+}
+
+func (s *Server) kick(r *http.Request, params []byte) (interface{}, error) {
+	a, err := s.arena(r)
+	if err != nil {
+		return nil, err
+	}
+	var req PlayerRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	return nil, a.Game().RemovePlayer(req.PlayerID)
+}
+
+// ban also takes the ball of the player out of every arena, a banned player can't join the chat nor vote
+func (s *Server) ban(banned bool) func(r *http.Request, params []byte) (interface{}, error) {
+	return func(r *http.Request, params []byte) (interface{}, error) {
+		var req PlayerRequest
+		if err := decode(params, &req); err != nil {
+			return nil, err
+		}
+		if _, err := s.db.Player.Get(req.PlayerID); err != nil {
+			return nil, fmt.Errorf("player %d: %w", req.PlayerID, errNotFound)
+		}
+		if err := s.db.Player.SetBanned(req.PlayerID, banned); err != nil {
+			return nil, err
+		}
+		if banned {
+			for _, a := range s.arenas.List() {
+				a.Game().RemovePlayer(req.PlayerID)
+			}
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) clearChat(r *http.Request, params []byte) (interface{}, error) {
+	if err := s.db.Message.Clear(); err != nil {
+		return nil, err
+	}
+	for _, a := range s.arenas.List() {
+		if err := s.app.GroupBroadcast(r.Context(), s.cfg.FrontendType, a.ChatGroup(), "onChatCleared", struct{}{}); err != nil {
+			zap.L().Error("broadcast chat cleared failed", zap.String("arena", a.ID), zap.Error(err))
+		}
+	}
+	return nil, nil
+}
+
+// shutdown applies the shutdown policy to every arena while the clients are still connected, then stops the server
+func (s *Server) shutdown(r *http.Request, params []byte) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), game.ShutdownTimeout(s.cfg.Shutdown.Timeout))
+	defer cancel()
+	s.arenas.Shutdown(ctx)
+	// leaves time for the answer and the last messages to reach the clients
+	time.AfterFunc(shutdownDelay, s.app.Shutdown)
+	return nil, nil
+}
+
+func (s *Server) mint(r *http.Request, params []byte) (interface{}, error) {
+	var req MintRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AccountName == "" {
+		return nil, &badRequest{"account_name is required"}
+	}
+	reward := &model.Reward{GameID: req.GameID, ArenaID: r.URL.Query().Get("arena"), AccountName: req.AccountName, Status: model.RewardPending}
+	if err := s.db.Reward.Create(reward); err != nil {
+		return nil, err
+	}
+	if err := game.MintReward(s.db, s.sdkClient, s.cfg, reward); err != nil {
+		return reward, err
+	}
+	return reward, nil
+}
+
+func (s *Server) retry(r *http.Request, params []byte) (interface{}, error) {
+	var req RetryRequest
+	if err := decode(params, &req); err != nil {
+		return nil, err
+	}
+	reward, err := s.db.Reward.Get(req.ID)
+	if err != nil {
+		return nil, fmt.Errorf("reward %d: %w", req.ID, errNotFound)
+	}
+	if reward.Status != model.RewardFailed {
+		return nil, fmt.Errorf("reward %d is %s, only failed rewards are retried: %w", req.ID, reward.Status, errConflict)
+	}
+	claimed, err := s.db.Reward.Claim(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, fmt.Errorf("reward %d is already being retried: %w", req.ID, errConflict)
+	}
+	reward.Status = model.RewardPending
+	if err := game.MintReward(s.db, s.sdkClient, s.cfg, reward); err != nil {
+		return reward, err
+	}
+	return reward, nil
+}
+
+func (s *Server) rewards(r *http.Request) (interface{}, error) {
+	return s.db.Reward.List(r.URL.Query().Get("status"), limit(r))
+}
+
+func (s *Server) audit(r *http.Request) (interface{}, error) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	return s.db.Audit.List(offset, limit(r))
+}
+
+func limit(r *http.Request) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		return n
+	}
+	return defaultList
+}
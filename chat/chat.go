@@ -23,20 +23,15 @@ type Room struct {
 	cfg       *config.Config
 	db        *db.Client
 	sdkClient *sdk.Client
-	game      *game.Game
+	arenas    *game.Arenas
 }
 
-func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config, game *game.Game) {
-	err := app.GroupCreate(context.Background(), config.ChatRoomName)
-	if err != nil {
-		panic(err)
-	}
-
+func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config, arenas *game.Arenas) {
 	app.Register(&Room{
-		app:  app,
-		db:   db,
-		cfg:  cfg,
-		game: game,
+		app:    app,
+		db:     db,
+		cfg:    cfg,
+		arenas: arenas,
 	},
 		component.WithName(config.ChatRoomName),
 		component.WithNameFunc(strings.ToLower),
@@ -70,6 +65,13 @@ func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, e
 	if err != nil && err != constants.ErrSessionAlreadyBound {
 		return nil, pitaya.Error(err, "RH-000", map[string]string{"failed": "bind"})
 	}
+	if game.IsBotID(player.PlayerID) {
+		return nil, pitaya.Error(fmt.Errorf("player id %d reserved to bots", player.PlayerID), "RH-400", map[string]string{"failed": "player id reserved"})
+	}
+	player.Bot = false
+	if old, err := r.db.Player.Get(player.PlayerID); err == nil && old.Banned {
+		return nil, pitaya.Error(fmt.Errorf("player %d banned", player.PlayerID), "RH-403", map[string]string{"failed": "player banned"})
+	}
 	//delete .zec suffix use zecrey nft sdk not need add .zec suffix will add it automatic
 	name := strings.TrimSuffix(player.Name, ".zec")
 	//set playerPk
@@ -91,15 +93,16 @@ func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, e
 		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "create player, db issue"})
 	}
 
-	// new user join group
-	r.app.GroupAddMember(ctx, config.ChatRoomName, s.UID()) // add session to group
+	// new user join the chat of the arena picked with game.join
+	arena := r.arenas.FromSession(s)
+	r.app.GroupAddMember(ctx, arena.ChatGroup(), s.UID()) // add session to group
 
 	// on session close, remove it from group
 	s.OnClose(func() {
-		r.app.GroupRemoveMember(ctx, config.ChatRoomName, s.UID())
+		r.app.GroupRemoveMember(ctx, r.arenas.FromSession(s).ChatGroup(), s.UID())
 	})
 
-	info, err := r.game.GetGameInfo()
+	info, err := arena.Game().GetGameInfo()
 	if err != nil {
 		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "get game info", "error": err.Error()})
 	}
@@ -110,6 +113,8 @@ func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, e
 // Message sync last message to all members
 func (r *Room) Message(ctx context.Context, msg *model.Message) (*MessageResponse, error) {
 	//fmt.Println("msg:", msg.Message)
+	arena := r.arenas.FromSession(r.app.GetSessionFromCtx(ctx))
+	msg.ArenaID = arena.ID
 	err := r.db.Message.Create(msg)
 	if err != nil {
 		zap.L().Error("save message failed", zap.Error(err))
@@ -134,21 +139,31 @@ func (r *Room) Message(ctx context.Context, msg *model.Message) (*MessageRespons
 		return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "get player, playerID not found"})
 	}
 
+	if p.Banned {
+		return nil, pitaya.Error(fmt.Errorf("player %d banned", p.PlayerID), "RH-403", map[string]string{"failed": "player banned"})
+	}
+
 	msg.Player = p
-	err = r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.ChatRoomName, "onMessage", msg)
+	err = r.app.GroupBroadcast(ctx, r.cfg.FrontendType, arena.ChatGroup(), "onMessage", msg)
 	if err != nil {
 		zap.L().Error("broadcast message failed", zap.Error(err))
 	}
 
-	if camp := game.DecideCamp(msg.Message); camp != game.Empty && r.game != nil {
-		r.game.StartRound(player.Name) //start by first people
+	if camp := game.DecideCamp(msg.Message); camp != game.Empty {
+		g := arena.Game()
+		if !g.AcceptsVotes() {
+			return nil, pitaya.Error(fmt.Errorf("round over"), "RH-409", map[string]string{"failed": "round over, vote in the next lobby"})
+		}
+		if err := g.StartRound(player.Name); err != nil { //start by first people
+			return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "start round"})
+		}
 		if err := r.db.Player.AddVote(&model.PlayerVote{
-			GameID:   r.game.GetGameID(),
+			GameID:   g.GetGameID(),
 			PlayerID: msg.PlayerID,
 			Camp:     uint8(camp),
 		}); err == nil {
-			r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onPlayerJoin", p)
-			r.game.AddPlayer(msg.PlayerID, game.DecideCamp(msg.Message))
+			r.app.GroupBroadcast(ctx, r.cfg.FrontendType, arena.GameGroup(), "onPlayerJoin", p)
+			g.Vote(msg.PlayerID, camp)
 		} else {
 			zap.L().Error("add player vote failed", zap.Error(err))
 		}
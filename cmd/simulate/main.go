@@ -0,0 +1,309 @@
+// Command simulate plays rounds of the game engine headlessly, with synthetic votes and no database nor NFT,
+// and reports the win rate and territory of every camp and the use of the items.
+//
+//	go run ./cmd/simulate -config config/config.json -rounds 500 -players 30 -timeline early -format csv
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/game"
+)
+
+const (
+	TimelineUniform = "uniform" // players join at any time of the round
+	TimelineEarly   = "early"   // players join in the first tenth of the round
+	TimelineLate    = "late"    // players join in the second half of the round
+	TimelineBurst   = "burst"   // every player joins at the start
+)
+
+var (
+	configPath = flag.String("config", "", "Path to a config file, the engine defaults are used without it")
+	arenaID    = flag.String("arena", "", "Arena of the config whose overrides are applied")
+	rounds     = flag.Int("rounds", 100, "Rounds to play")
+	players    = flag.Int("players", 20, "Players voting in each round")
+	timeline   = flag.String("timeline", TimelineUniform, "When the players join: uniform, early, late or burst")
+	weights    = flag.String("weights", "", "Vote weight of the camps, e.g. BTC=2,ETH=1, the camps not listed weigh 1")
+	seed       = flag.Int64("seed", 1, "Seed of the first round, round i is played with seed+i")
+	sample     = flag.Int("sample", 1, "Seconds between two samples of the territory curve")
+	format     = flag.String("format", "json", "Output format: json or csv")
+	outPath    = flag.String("out", "", "Output file, stdout when empty")
+)
+
+type CampSummary struct {
+	Camp          game.Camp `json:"camp"`
+	Tag           string    `json:"tag"`
+	Wins          int       `json:"wins"`
+	Draws         int       `json:"draws"` // rounds it shared the win
+	WinRate       float64   `json:"win_rate"`
+	AvgFinalShare float64   `json:"avg_final_share"`
+	Territory     []float64 `json:"territory"` // average share of the map at each sample
+}
+
+type ItemSummary struct {
+	Name       string  `json:"name"`
+	Spawned    int     `json:"spawned"`
+	PickedUp   int     `json:"picked_up"`
+	PickupRate float64 `json:"pickup_rate"`
+	PerRound   float64 `json:"per_round"` // picked up
+}
+
+type Summary struct {
+	Rounds        int            `json:"rounds"`
+	Players       int            `json:"players"`
+	Timeline      string         `json:"timeline"`
+	SampleSeconds int            `json:"sample_seconds"`
+	AvgSeconds    float64        `json:"avg_seconds"` // rounds may end before the timer
+	Conditions    map[string]int `json:"conditions"`
+	Camps         []CampSummary  `json:"camps"`
+	Items         []ItemSummary  `json:"items"`
+}
+
+func main() {
+	flag.Parse()
+	cfg, err := loadConfig()
+	if err != nil {
+		fail(err)
+	}
+	campWeights, err := parseWeights(*weights)
+	if err != nil {
+		fail(err)
+	}
+	switch *timeline {
+	case TimelineUniform, TimelineEarly, TimelineLate, TimelineBurst:
+	default:
+		fail(fmt.Errorf("unknown timeline %q", *timeline))
+	}
+	if *rounds <= 0 || *players <= 0 || *sample <= 0 {
+		fail(fmt.Errorf("rounds, players and sample must be positive"))
+	}
+
+	tickRate := game.TickRate(cfg)
+	roundTicks := uint32(cfg.GameDuration * tickRate)
+	sampleTicks := uint32(*sample * tickRate)
+
+	results := make([]game.SimResult, 0, *rounds)
+	for i := 0; i < *rounds; i++ {
+		s := *seed + int64(i)
+		results = append(results, game.Simulate(cfg, s, voteTimeline(s, roundTicks, campWeights), sampleTicks))
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	summary := summarize(results, tickRate)
+	if *format == "csv" {
+		err = writeCSV(out, summary)
+	} else {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(summary)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "simulate:", err)
+	os.Exit(1)
+}
+
+func loadConfig() (*config.Config, error) {
+	cfg := &config.Config{FPS: 30, GameDuration: 60, ItemFrameChance: 500, Camps: config.DefaultCamps}
+	if *configPath != "" {
+		cfg = config.Read(*configPath)
+		if *arenaID != "" {
+			var err error
+			if cfg, err = arenaConfig(cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cfg.FPS <= 0 || cfg.GameDuration <= 0 || cfg.ItemFrameChance <= 0 {
+		return nil, fmt.Errorf("fps, game_duration and item_frame_chance must be positive")
+	}
+	if err := game.SetCamps(cfg.Camps); err != nil {
+		return nil, err
+	}
+	if _, err := game.LoadMapLayout(cfg.Map); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func arenaConfig(cfg *config.Config) (*config.Config, error) {
+	for _, a := range cfg.Arenas {
+		if a.ID == *arenaID {
+			return cfg.ForArena(a)
+		}
+	}
+	return nil, fmt.Errorf("arena %s not found", *arenaID)
+}
+
+// parseWeights reads BTC=2,ETH=1 into a weight per camp of the registry
+func parseWeights(s string) (map[game.Camp]int, error) {
+	w := map[game.Camp]int{}
+	for _, c := range game.Camps {
+		w[c.Camp] = 1
+	}
+	if s == "" {
+		return w, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad weight %q", kv)
+		}
+		camp, ok := game.CampTagMapReverse[strings.ToUpper(strings.TrimSpace(parts[0]))]
+		if !ok {
+			return nil, fmt.Errorf("unknown camp %q", parts[0])
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad weight %q", kv)
+		}
+		w[camp] = n
+	}
+	return w, nil
+}
+
+// voteTimeline draws the players of a round, the same seed gives the same timeline
+func voteTimeline(s int64, roundTicks uint32, campWeights map[game.Camp]int) []game.SimVote {
+	rng := rand.New(rand.NewSource(s))
+	total := 0
+	for _, c := range game.Camps {
+		total += campWeights[c.Camp]
+	}
+	votes := make([]game.SimVote, 0, *players)
+	for i := 0; i < *players; i++ {
+		var tick uint32
+		switch *timeline {
+		case TimelineUniform:
+			tick = uint32(rng.Int63n(int64(roundTicks)))
+		case TimelineEarly:
+			tick = uint32(rng.Int63n(int64(roundTicks/10 + 1)))
+		case TimelineLate:
+			tick = roundTicks/2 + uint32(rng.Int63n(int64(roundTicks-roundTicks/2)))
+		}
+		camp := game.Camps[0].Camp
+		if total > 0 {
+			n := rng.Intn(total)
+			for _, c := range game.Camps {
+				if n < campWeights[c.Camp] {
+					camp = c.Camp
+					break
+				}
+				n -= campWeights[c.Camp]
+			}
+		}
+		votes = append(votes, game.SimVote{Tick: tick, PlayerID: uint64(i + 1), Camp: camp})
+	}
+	return votes
+}
+
+func summarize(results []game.SimResult, tickRate int) Summary {
+	s := Summary{
+		Rounds:        len(results),
+		Players:       *players,
+		Timeline:      *timeline,
+		SampleSeconds: *sample,
+		Conditions:    map[string]int{},
+	}
+	samples := 0
+	for _, r := range results {
+		if len(r.Territory) > samples {
+			samples = len(r.Territory)
+		}
+	}
+	n := float64(len(results))
+	for i, c := range game.Camps {
+		cs := CampSummary{Camp: c.Camp, Tag: c.Tag, Territory: make([]float64, samples)}
+		for _, r := range results {
+			if r.Draw {
+				for _, w := range r.Winners {
+					if w == c.Camp {
+						cs.Draws++
+					}
+				}
+			} else if r.Winner == c.Camp {
+				cs.Wins++
+			}
+			// a round over before the timer keeps its final territory until the end of the curve
+			for j := 0; j < samples; j++ {
+				k := j
+				if k >= len(r.Territory) {
+					k = len(r.Territory) - 1
+				}
+				cs.Territory[j] += r.Territory[k][i] / n
+			}
+			cs.AvgFinalShare += r.Territory[len(r.Territory)-1][i] / n
+		}
+		cs.WinRate = float64(cs.Wins) / n
+		s.Camps = append(s.Camps, cs)
+	}
+
+	spawned, picked := map[game.ItemType]int{}, map[game.ItemType]int{}
+	ticks := 0.0
+	for _, r := range results {
+		s.Conditions[r.Condition]++
+		ticks += float64(r.Ticks)
+		for t, k := range r.Spawned {
+			spawned[t] += k
+		}
+		for t, k := range r.PickedUp {
+			picked[t] += k
+		}
+	}
+	s.AvgSeconds = ticks / n / float64(tickRate)
+	for _, item := range game.AllItems {
+		is := ItemSummary{Name: item.Name, Spawned: spawned[item.Type], PickedUp: picked[item.Type], PerRound: float64(picked[item.Type]) / n}
+		if is.Spawned > 0 {
+			is.PickupRate = float64(is.PickedUp) / float64(is.Spawned)
+		}
+		s.Items = append(s.Items, is)
+	}
+	return s
+}
+
+// writeCSV writes the camps then, after an empty line, the items
+func writeCSV(out io.Writer, s Summary) error {
+	w := csv.NewWriter(out)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	header := []string{"camp", "tag", "wins", "draws", "win_rate", "avg_final_share"}
+	if len(s.Camps) > 0 {
+		for i := range s.Camps[0].Territory {
+			header = append(header, fmt.Sprintf("share_%ds", (i+1)*s.SampleSeconds))
+		}
+	}
+	w.Write(header)
+	for _, c := range s.Camps {
+		row := []string{strconv.Itoa(int(c.Camp)), c.Tag, strconv.Itoa(c.Wins), strconv.Itoa(c.Draws), f(c.WinRate), f(c.AvgFinalShare)}
+		for _, v := range c.Territory {
+			row = append(row, f(v))
+		}
+		w.Write(row)
+	}
+	w.Write(nil)
+	w.Write([]string{"item", "spawned", "picked_up", "pickup_rate", "per_round"})
+	for _, i := range s.Items {
+		w.Write([]string{i.Name, strconv.Itoa(i.Spawned), strconv.Itoa(i.PickedUp), f(i.PickupRate), f(i.PerRound)})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/ZecreyGaming/BlockChainWar/db"
@@ -10,18 +11,119 @@ import (
 const (
 	ChatRoomName = "chat"
 	GameRoomName = "game"
+
+	DefaultArenaID = "default"
 )
 
+// Arena is a game running next to the others in the same process, Config overrides
+// any field of the top level config for this arena only
+type Arena struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config"`
+}
+
+// CampConfig defines a camp players can vote for, ID is what the cells of a frame hold so it must be
+// between 1 and 15. Spawn is the cell column, row of the center of the camp, the map spawns override it.
+type CampConfig struct {
+	ID       uint8    `json:"id"`
+	Tag      string   `json:"tag"`
+	Name     string   `json:"name"`
+	Color    string   `json:"color"`
+	Icon     string   `json:"icon"`
+	Spawn    *[2]int  `json:"spawn,omitempty"`
+	Keywords []string `json:"keywords,omitempty"` // chat words voting for the camp, the tag always does
+}
+
+// DefaultCamps are played when the config has no camps, their spawns are scaled to the size of the map
+var DefaultCamps = []CampConfig{
+	{ID: 1, Tag: "BTC", Name: "Bitcoin", Color: "#E53935", Icon: "https://example.com/red.png"},
+	{ID: 2, Tag: "ETH", Name: "Ethereum", Color: "#1E88E5", Icon: "https://example.com/blue.png"},
+	{ID: 3, Tag: "BNB", Name: "Binance", Color: "#43A047", Icon: "https://example.com/green.png"},
+	{ID: 4, Tag: "AVAX", Name: "Avalanche", Color: "#FDD835", Icon: "https://example.com/yellow.png"},
+	{ID: 5, Tag: "MATIC", Name: "Polygon", Color: "#8E24AA", Icon: "https://example.com/purple.png"},
+}
+
+// MapConfig sets the size of the map and where the camps spawn, zero values keep the defaults.
+// File points to a json file with the same fields, the fields set here override the file.
+// Grid draws the tiles of the map, one string per row: '.' normal, '#' wall, 'o' hole, '+' bonus.
+type MapConfig struct {
+	File       string            `json:"file,omitempty"`
+	Row        int               `json:"row,omitempty"`
+	Column     int               `json:"column,omitempty"`
+	CellWidth  int               `json:"cell_width,omitempty"`
+	CellHeight int               `json:"cell_height,omitempty"`
+	Spawns     map[string][2]int `json:"spawns,omitempty"` // camp tag -> cell column, row
+	Grid       []string          `json:"grid,omitempty"`
+	BonusValue int               `json:"bonus_value,omitempty"` // cells a bonus tile counts for
+}
+
+// WinConfig adds ways to win a round besides holding the most cells when the time is up
+type WinConfig struct {
+	Territory int  `json:"territory,omitempty"`  // percent of the map, a camp holding that much wins at once, 0 disables it
+	LastCamp  bool `json:"last_camp,omitempty"`  // the round ends when a single camp still has cells
+	SubRounds int  `json:"sub_rounds,omitempty"` // split the round, the map is reset between sub-rounds and the camp winning most of them wins
+	// TieBreak decides between camps with the same score when the time is up: "shared" (default) is a draw,
+	// "votes" picks the camp with the most votes and "earliest" the one that reached its score first
+	TieBreak string `json:"tie_break,omitempty"`
+}
+
+// ScheduleConfig starts the rounds on their own: a lobby counting down while votes come in, the round,
+// then the results for a while before the next lobby
+type ScheduleConfig struct {
+	Enabled    bool `json:"enabled"`
+	Lobby      int  `json:"lobby"`       // seconds
+	Results    int  `json:"results"`     // seconds, 0 uses game_round_interval
+	MinPlayers int  `json:"min_players"` // the lobby countdown waits for that many players
+}
+
+// CheckpointConfig saves the running round regularly so that a restart doesn't lose it
+type CheckpointConfig struct {
+	Interval  int    `json:"interval,omitempty"`   // seconds, 0 is 5, negative disables the checkpoints
+	OnRestart string `json:"on_restart,omitempty"` // "resume" (default) goes on with the round, "void" voids it
+}
+
+// BotConfig adds server balls to the camps short of players, bots only join a round with at least one player
+type BotConfig struct {
+	Mode       string `json:"mode,omitempty"`         // "" disables the bots, "min_per_camp" or "balance" fills every camp up to the biggest one
+	MinPerCamp int    `json:"min_per_camp,omitempty"` // balls of every camp in min_per_camp mode
+	MaxBots    int    `json:"max_bots,omitempty"`     // bots in a round, 0 is no limit
+	Profile    string `json:"profile,omitempty"`      // "passive", "normal" (default) or "aggressive"
+}
+
+// ShutdownConfig decides what happens to the running rounds when the server stops
+type ShutdownConfig struct {
+	Policy  string `json:"policy,omitempty"`  // "finish" (default) ends and scores the round, "void" voids it, "resume" checkpoints it
+	Timeout int    `json:"timeout,omitempty"` // seconds to wait for the database and the NFT mint, 0 is 10
+}
+
 type Config struct {
-	Database          db.Config `json:"database"`
-	FPS               int       `json:"fps"`
-	GameRoundInterval int       `json:"game_round_interval"`
-	FrontendType      string    `json:"frontend_type"`
-	ItemFrameChance   int       `json:"item_frame_chance"`
-	GameDuration      int       `json:"game_duration"`
-	Seed              string    `json:"seed"`
-	NftPrefix         string    `json:"nft_prefix"`
-	CollectionId      int64     `json:"collection_id"`
+	Database          db.Config         `json:"database"`
+	FPS               int               `json:"fps"`
+	TickRate          int               `json:"tick_rate"`
+	KeyframeInterval  int               `json:"keyframe_interval"`
+	GameRoundInterval int               `json:"game_round_interval"`
+	FrontendType      string            `json:"frontend_type"`
+	ItemFrameChance   int               `json:"item_frame_chance"`
+	ItemWeights       map[string]int    `json:"item_weights,omitempty"`    // item name -> spawn weight, overrides the default weights
+	ItemTTL           int               `json:"item_ttl,omitempty"`        // seconds an item stays on the map, 0 is 15
+	MaxItems          int               `json:"max_items,omitempty"`       // items on the map at the same time, 0 is 5
+	MaxVelocity       float64           `json:"max_velocity,omitempty"`    // pixels per second, 0 is half a cell per tick
+	BallCollisions    string            `json:"ball_collisions,omitempty"` // off, enemy or all
+	GameDuration      int               `json:"game_duration"`
+	Win               WinConfig         `json:"win"`
+	Schedule          ScheduleConfig    `json:"schedule"`
+	Checkpoint        CheckpointConfig  `json:"checkpoint"`
+	Shutdown          ShutdownConfig    `json:"shutdown"`
+	Bots              BotConfig         `json:"bots"`
+	RoundSeed         int64             `json:"round_seed"`
+	Seed              string            `json:"seed"`
+	NftPrefix         string            `json:"nft_prefix"`
+	CollectionId      int64             `json:"collection_id"`
+	AdminTokens       map[string]string `json:"admin_tokens,omitempty"` // bearer token -> operator name in the audit log, empty disables the admin api
+	Map               MapConfig         `json:"map"`
+	Camps             []CampConfig      `json:"camps"` // shared by every arena
+	Arenas            []Arena           `json:"arenas"`
 }
 
 func Read(configPath string) *Config {
@@ -33,5 +135,57 @@ func Read(configPath string) *Config {
 	if err := json.Unmarshal(b, &config); err != nil {
 		panic(err)
 	}
+	if len(config.Camps) == 0 {
+		config.Camps = DefaultCamps
+	}
+	if len(config.Arenas) == 0 {
+		config.Arenas = []Arena{{ID: DefaultArenaID, Name: "Standard"}}
+	}
+	ids := map[string]bool{}
+	for _, a := range config.Arenas {
+		if a.ID == "" || ids[a.ID] {
+			panic(fmt.Sprintf("arena id %q is empty or duplicated", a.ID))
+		}
+		ids[a.ID] = true
+	}
 	return &config
 }
+
+// ForArena returns a copy of the config with the overrides of the arena applied
+func (c *Config) ForArena(a Arena) (*Config, error) {
+	// deep copy through json, overrides must not leak into the maps of the top level config
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	cfg.Arenas = nil
+	if len(a.Config) > 0 {
+		var overrides struct {
+			Camps json.RawMessage `json:"camps"`
+		}
+		if err := json.Unmarshal(a.Config, &overrides); err != nil {
+			return nil, fmt.Errorf("arena %s config: %w", a.ID, err)
+		}
+		if overrides.Camps != nil {
+			return nil, fmt.Errorf("arena %s config: camps are shared by every arena", a.ID)
+		}
+		if err := json.Unmarshal(a.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("arena %s config: %w", a.ID, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// GameGroupName is the pitaya group the frames of an arena are broadcast to
+func GameGroupName(arenaID string) string {
+	return GameRoomName + "_" + arenaID
+}
+
+// ChatGroupName is the pitaya group the chat of an arena is broadcast to
+func ChatGroupName(arenaID string) string {
+	return ChatRoomName + "_" + arenaID
+}
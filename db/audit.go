@@ -0,0 +1,17 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+type audit db
+
+func (a *audit) Create(log *model.AuditLog) error {
+	return a.db.Create(log).Error
+}
+
+func (a *audit) List(offset, limit int) ([]model.AuditLog, error) {
+	var logs []model.AuditLog
+	err := a.db.Order("id desc").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"fmt"
+
 	"github.com/ZecreyGaming/BlockChainWar/model"
 	"gorm.io/gorm"
 )
@@ -11,12 +13,64 @@ func (c *camp) Create(camp *model.Camp) error {
 	return c.db.Create(camp).Error
 }
 
+// Sync upserts the configured camps and soft-deletes the others. A camp keeps its score unless its
+// name or tag changed, it's another camp then.
+func (c *camp) Sync(camps []model.Camp) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.Camp
+		if err := tx.Unscoped().Find(&existing).Error; err != nil {
+			return err
+		}
+		names := map[string]uint8{}
+		ids := make([]uint8, 0, len(camps))
+		for _, camp := range camps {
+			names[camp.Name] = camp.ID
+			ids = append(ids, camp.ID)
+		}
+		// the names moving to another camp are freed first, swapping two names would break the unique index
+		rows := map[uint8]model.Camp{}
+		for _, e := range existing {
+			rows[e.ID] = e
+			if id, ok := names[e.Name]; ok && id != e.ID {
+				if err := tx.Unscoped().Model(&model.Camp{ID: e.ID}).Update("name", fmt.Sprintf("%s#%d", e.Name, e.ID)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, camp := range camps {
+			e, ok := rows[camp.ID]
+			if !ok {
+				if err := tx.Create(&camp).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			score := e.Score
+			if e.Name != camp.Name || e.ShortName != camp.ShortName {
+				score = 0
+			}
+			if err := tx.Unscoped().Model(&model.Camp{ID: camp.ID}).Updates(map[string]interface{}{
+				"name":       camp.Name,
+				"short_name": camp.ShortName,
+				"color":      camp.Color,
+				"icon":       camp.Icon,
+				"score":      score,
+				"deleted_at": nil,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id NOT IN ?", ids).Delete(&model.Camp{}).Error
+	})
+}
+
 func (c *camp) IncreaseScore(campID uint8) error {
 	return c.db.Model(&model.Camp{}).Where("id = ?", campID).Update("score", gorm.Expr("score + ?", 1)).Error
 }
 
-func (c *camp) ListRank(limit int) ([]model.Camp, error) {
+// ListRank ranks the camps of ids, the camps dropped from the config are left out
+func (c *camp) ListRank(limit int, ids []uint8) ([]model.Camp, error) {
 	var camps []model.Camp
-	err := c.db.Order("score desc").Limit(limit).Find(&camps).Error
+	err := c.db.Where("id IN ?", ids).Order("score desc").Limit(limit).Find(&camps).Error
 	return camps, err
 }
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type checkpoint db
+
+// Save replaces the checkpoint of the arena and adds the replay parts recorded since the previous one
+func (c *checkpoint) Save(cp *model.Checkpoint, parts []model.CheckpointReplay) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		if len(parts) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&parts).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(cp).Error
+	})
+}
+
+func (c *checkpoint) Get(arenaID string) (*model.Checkpoint, error) {
+	var cp model.Checkpoint
+	err := c.db.Where("arena_id = ?", arenaID).First(&cp).Error
+	return &cp, err
+}
+
+// ReplayParts returns the replay parts of the round in order
+func (c *checkpoint) ReplayParts(arenaID string, gameID uint) ([]model.CheckpointReplay, error) {
+	var parts []model.CheckpointReplay
+	err := c.db.Where("arena_id = ? AND game_id = ?", arenaID, gameID).Order("seq").Find(&parts).Error
+	return parts, err
+}
+
+// Delete removes the checkpoint of the round and its replay parts, the checkpoint of a later round is kept
+func (c *checkpoint) Delete(arenaID string, gameID uint) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("arena_id = ? AND game_id = ?", arenaID, gameID).Delete(&model.Checkpoint{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("arena_id = ? AND game_id = ?", arenaID, gameID).Delete(&model.CheckpointReplay{}).Error
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/ZecreyGaming/BlockChainWar/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type Config struct {
@@ -20,10 +19,16 @@ type Config struct {
 
 type Client struct {
 	*gorm.DB
-	Game    game
-	Camp    camp
-	Player  player
-	Message message
+	Game       game
+	Camp       camp
+	Player     player
+	Message    message
+	Replay     replay
+	Result     result
+	Reward     reward
+	Audit      audit
+	Checkpoint checkpoint
+	Stats      stats
 }
 
 type db struct {
@@ -51,19 +56,11 @@ func NewClient(cfg Config) *Client {
 		}
 	}
 
-	err = gdb.AutoMigrate(&model.Message{}, &model.Game{}, &model.Player{}, &model.Camp{}, &model.PlayerVote{})
+	err = gdb.AutoMigrate(&model.Message{}, &model.Game{}, &model.Player{}, &model.Camp{}, &model.PlayerVote{}, &model.Replay{}, &model.CampResult{}, &model.Reward{}, &model.AuditLog{}, &model.Checkpoint{}, &model.CheckpointReplay{}, &model.PlayerGameStats{})
 	if err != nil {
 		panic(err)
 	}
 
-	err = gdb.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "name"}},
-		UpdateAll: true,
-	}).Create(&model.Camps).Error
-	if err != nil {
-		panic(err)
-	}
-
-	return &Client{DB: gdb, Game: game{db: gdb}, Camp: camp{db: gdb}, Player: player{db: gdb}, Message: message{db: gdb}}
+	return &Client{DB: gdb, Game: game{db: gdb}, Camp: camp{db: gdb}, Player: player{db: gdb}, Message: message{db: gdb}, Replay: replay{db: gdb}, Result: result{db: gdb}, Reward: reward{db: gdb}, Audit: audit{db: gdb}, Checkpoint: checkpoint{db: gdb}, Stats: stats{db: gdb}}
 	// return &Client{}
 }
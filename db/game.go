@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/ZecreyGaming/BlockChainWar/model"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -15,11 +17,26 @@ func (g *game) Create(game *model.Game) error {
 func (g *game) Update(game *model.Game) error {
 	return g.db.Updates(game).Error
 }
-func (g *game) GetLastWinner() (*model.Game, error) {
+// GetLastWinner returns the last finished round of the arena, the one of the lobby or still running isn't
+func (g *game) GetLastWinner(arenaID string) (*model.Game, error) {
 	var _game *model.Game
-	db := g.db.Preload(clause.Associations).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).Limit(1).Find(&_game)
+	db := g.db.Preload(clause.Associations).Where("arena_id = ? AND win_condition <> ? AND voided = ?", arenaID, "", false).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).Limit(1).Find(&_game)
 	if db.RowsAffected == 0 {
 		return nil, gorm.ErrRecordNotFound
 	}
 	return _game, db.Error
 }
+
+func (g *game) Get(id uint) (*model.Game, error) {
+	var _game model.Game
+	err := g.db.First(&_game, id).Error
+	return &_game, err
+}
+
+// VoidUnfinished marks the rounds of the arena that never got a result as voided, except the one kept
+func (g *game) VoidUnfinished(arenaID string, keepID uint) (int64, error) {
+	db := g.db.Model(&model.Game{}).
+		Where("arena_id = ? AND win_condition = ? AND voided = ? AND id <> ?", arenaID, "", false, keepID).
+		Updates(map[string]interface{}{"voided": true, "end_time": time.Now()})
+	return db.RowsAffected, db.Error
+}
@@ -12,15 +12,21 @@ func (m *message) Create(message *model.Message) error {
 	return m.db.Create(message).Error
 }
 
-func (m *message) ListLatest(offset, size int) ([]model.Message, error) {
+// ListLatest returns the messages of the chat of the arena, newest first
+func (m *message) ListLatest(arenaID string, offset, size int) ([]model.Message, error) {
 	var messages []model.Message
 	// if err := m.db.Debug().Model(&model.Message{}).Preload("Player").Order("created_at desc").Offset(offset).Limit(size).Find(&messages).Error; err != nil && err != gorm.ErrRecordNotFound {
 	// 	return nil, err
 	// }
-	err := m.db.Preload(clause.Associations).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).Offset(offset).Limit(size).Find(&messages).Error
+	err := m.db.Preload(clause.Associations).Where("arena_id = ?", arenaID).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).Offset(offset).Limit(size).Find(&messages).Error
 	if err == gorm.ErrRecordNotFound {
 		err = nil
 	}
 
 	return messages, nil
 }
+
+// Clear deletes every message, they're soft deleted and kept in the table
+func (m *message) Clear() error {
+	return m.db.Where("1 = 1").Delete(&model.Message{}).Error
+}
@@ -9,12 +9,17 @@ import (
 type player db
 
 func (p *player) Create(player *model.Player) error {
+	// a ban is only lifted from the admin API, joining again keeps it
 	return p.db.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "player_id"}},
-		UpdateAll: true,
+		DoUpdates: clause.AssignmentColumns([]string{"name", "l2public_key", "score", "thumbnail", "updated_at", "deleted_at"}),
 	}).Create(player).Error
 }
 
+func (p *player) SetBanned(playerID uint64, banned bool) error {
+	return p.db.Model(&model.Player{}).Where("player_id = ?", playerID).Update("banned", banned).Error
+}
+
 func (p *player) Get(playerID uint64) (model.Player, error) {
 	var player model.Player
 	err := p.db.First(&player, "player_id = ?", playerID).Error
@@ -29,7 +34,7 @@ func (p *player) List(playeIDs ...uint64) ([]model.Player, error) {
 
 func (p *player) ListRank(limit int) ([]model.Player, error) {
 	var players []model.Player
-	err := p.db.Order("score desc").Limit(limit).Find(&players).Error
+	err := p.db.Where("bot = ?", false).Order("score desc").Limit(limit).Find(&players).Error
 	return players, err
 }
 
@@ -0,0 +1,17 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+type replay db
+
+func (r *replay) Create(replay *model.Replay) error {
+	return r.db.Create(replay).Error
+}
+
+func (r *replay) GetByGameID(gameID uint) (*model.Replay, error) {
+	var replay model.Replay
+	err := r.db.First(&replay, "game_id = ?", gameID).Error
+	return &replay, err
+}
@@ -0,0 +1,20 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+type result db
+
+func (r *result) Create(results []model.CampResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	return r.db.Create(&results).Error
+}
+
+func (r *result) ListByGame(gameID uint) ([]model.CampResult, error) {
+	var results []model.CampResult
+	err := r.db.Where("game_id = ?", gameID).Order("camp").Find(&results).Error
+	return results, err
+}
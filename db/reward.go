@@ -0,0 +1,38 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+type reward db
+
+func (r *reward) Create(reward *model.Reward) error {
+	return r.db.Create(reward).Error
+}
+
+func (r *reward) Update(reward *model.Reward) error {
+	return r.db.Save(reward).Error
+}
+
+// Claim sets a failed reward back to pending, false when it isn't failed anymore so that
+// two retries never mint the same reward
+func (r *reward) Claim(id uint) (bool, error) {
+	res := r.db.Model(&model.Reward{}).Where("id = ? AND status = ?", id, model.RewardFailed).Update("status", model.RewardPending)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *reward) Get(id uint) (*model.Reward, error) {
+	var reward model.Reward
+	err := r.db.First(&reward, id).Error
+	return &reward, err
+}
+
+func (r *reward) List(status string, limit int) ([]model.Reward, error) {
+	var rewards []model.Reward
+	q := r.db.Order("id desc").Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Find(&rewards).Error
+	return rewards, err
+}
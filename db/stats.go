@@ -0,0 +1,34 @@
+package db
+
+import (
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+type stats db
+
+func (s *stats) Create(stats []model.PlayerGameStats) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	return s.db.Create(&stats).Error
+}
+
+func (s *stats) ListByGame(gameID uint) ([]model.PlayerGameStats, error) {
+	var stats []model.PlayerGameStats
+	err := s.db.Where("game_id = ?", gameID).Order("contribution desc, player_id").Find(&stats).Error
+	return stats, err
+}
+
+// ListRank sums the contribution of the players over every round, the voided rounds and the bots excluded
+func (s *stats) ListRank(limit int) ([]model.PlayerContribution, error) {
+	var rank []model.PlayerContribution
+	err := s.db.Model(&model.PlayerGameStats{}).
+		Select("player_game_stats.player_id, players.name AS player_name, SUM(player_game_stats.contribution) AS contribution, COUNT(*) AS games").
+		Joins("JOIN games ON games.id = player_game_stats.game_id AND games.voided = ?", false).
+		Joins("JOIN players ON players.player_id = player_game_stats.player_id AND players.bot = ?", false).
+		Group("player_game_stats.player_id, players.name").
+		Order("contribution desc").
+		Limit(limit).
+		Scan(&rank).Error
+	return rank, err
+}
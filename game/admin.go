@@ -0,0 +1,123 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotRunning  = errors.New("no round is running")
+	ErrRunning     = errors.New("a round is already running")
+	ErrBadDuration = errors.New("duration must be positive")
+	ErrNoPlayer    = errors.New("player not in the round")
+)
+
+// call runs f on the tick goroutine between two ticks and waits for its result, so that handlers
+// never touch the simulation while it's being updated. The snapshot is published before call returns.
+func (g *Game) call(f func() error) error {
+	return g.callContext(g.ctx, f)
+}
+
+// callContext is call giving up when ctx is done, f still runs if it was queued
+func (g *Game) callContext(ctx context.Context, f func() error) error {
+	done := make(chan error, 1)
+	select {
+	case g.commands <- func() {
+		err := f()
+		g.publish()
+		done <- err
+	}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	}
+}
+
+func (g *Game) applyCommands() {
+	for {
+		select {
+		case f := <-g.commands:
+			f()
+		default:
+			return
+		}
+	}
+}
+
+// ForceStart starts a round now, the lobby of a scheduled game is cut short
+func (g *Game) ForceStart(toRewardName string) error {
+	return g.call(func() error {
+		if g.GameStatus == GameRunning || g.GameStatus == GamePaused {
+			return ErrRunning
+		}
+		if g.scheduled() {
+			if g.phase != PhaseLobby {
+				g.enterLobby()
+			}
+			if g.toRewardName == "" {
+				g.toRewardName = toRewardName
+			}
+			g.runRound()
+			return nil
+		}
+		g.startRound(toRewardName)
+		return nil
+	})
+}
+
+// ForceStop ends the running round now, it's scored like a round that ran out of time
+func (g *Game) ForceStop() error {
+	return g.call(func() error {
+		if g.GameStatus != GameRunning && g.GameStatus != GamePaused {
+			return ErrNotRunning
+		}
+		g.endRound()
+		return nil
+	})
+}
+
+// SetDuration changes the duration of the next rounds and of the running one, counted from its start
+func (g *Game) SetDuration(seconds int) error {
+	if seconds <= 0 {
+		return ErrBadDuration
+	}
+	return g.call(func() error {
+		g.duration = seconds
+		if g.GameStatus == GameRunning || g.GameStatus == GamePaused {
+			g.roundTicks = g.secondsToTicks(seconds)
+			if g.dbGame != nil {
+				g.dbGame.EndTime = time.Now().Add(g.remaining())
+			}
+		}
+		return nil
+	})
+}
+
+// RemovePlayer takes the ball of the player and the balls split from it out of the round
+func (g *Game) RemovePlayer(playerID uint64) error {
+	return g.call(func() error {
+		if _, ok := g.Players.Load(playerID); !ok {
+			return ErrNoPlayer
+		}
+		g.Players.Range(func(key, value interface{}) bool {
+			if p, ok := value.(*Player); ok && (p.ID == playerID || p.owner == playerID) {
+				g.Players.Delete(key)
+				if p.playerObj != nil {
+					g.space.Remove(p.playerObj)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+}
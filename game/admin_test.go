@@ -0,0 +1,87 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+// command runs f while the test plays the tick goroutine
+func command(g *Game, f func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	for {
+		select {
+		case err := <-done:
+			return err
+		default:
+			g.applyCommands()
+		}
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, GameDuration: 10})
+	if err := command(g, g.Pause); err != ErrNotRunning {
+		t.Fatalf("pause before the round: %v", err)
+	}
+	if err := command(g, func() error { return g.ForceStart("") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := command(g, func() error { return g.ForceStart("") }); err != ErrRunning {
+		t.Fatalf("second start: %v", err)
+	}
+	g.AddPlayer(1, BTC)
+
+	var events []string
+	g.onPause = func(route string, gp GamePause) { events = append(events, route) }
+	if err := command(g, g.Pause); err != nil || g.GameStatus != GamePaused {
+		t.Fatalf("pause: %v, status %v", err, g.GameStatus)
+	}
+	if err := command(g, g.Pause); err != ErrNotRunning {
+		t.Fatalf("second pause: %v", err)
+	}
+	tick := g.tick
+	for i := 0; i < 10; i++ {
+		g.step()
+	}
+	if g.tick != tick {
+		t.Fatalf("paused round moved from tick %d to %d", tick, g.tick)
+	}
+	if err := command(g, g.Resume); err != nil {
+		t.Fatal(err)
+	}
+	g.step()
+	if g.tick != tick+1 {
+		t.Fatalf("resumed round at tick %d", g.tick)
+	}
+	if len(events) != 2 || events[0] != pausedRoute || events[1] != resumedRoute {
+		t.Fatalf("pause events %v", events)
+	}
+
+	if err := command(g, func() error { return g.SetDuration(20) }); err != nil || g.roundTicks != 600 {
+		t.Fatalf("duration: %v, %d ticks", err, g.roundTicks)
+	}
+	if g.cfg.GameDuration != 10 || g.Snapshot().Duration != 20 {
+		t.Fatalf("duration %d in the config, %d in the snapshot", g.cfg.GameDuration, g.Snapshot().Duration)
+	}
+	if v, ok := g.Players.Load(uint64(1)); ok {
+		split{}.Apply(g, v.(*Player), 0, 0)
+	}
+	if err := command(g, func() error { return g.RemovePlayer(1) }); err != nil {
+		t.Fatal(err)
+	}
+	g.Players.Range(func(key, value interface{}) bool {
+		if p := value.(*Player); p.ID == 1 || p.owner == 1 {
+			t.Fatalf("ball %d of the kicked player still in the round", p.ID)
+		}
+		return true
+	})
+	if err := command(g, func() error { return g.RemovePlayer(1) }); err != ErrNoPlayer {
+		t.Fatalf("second kick: %v", err)
+	}
+
+	if err := command(g, g.ForceStop); err != nil || g.GameStatus == GameRunning {
+		t.Fatalf("stop: %v, status %v", err, g.GameStatus)
+	}
+}
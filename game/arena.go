@@ -0,0 +1,247 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/db"
+	"github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/session"
+	"go.uber.org/zap"
+)
+
+const arenaSessionKey = "arena"
+
+// Arena is a game with its own pitaya groups and config, several of them run in one process
+type Arena struct {
+	ID   string
+	Name string
+
+	ctx       context.Context
+	app       pitaya.Pitaya
+	cfg       *config.Config
+	db        *db.Client
+	game      *Game
+	gameGroup string
+	chatGroup string
+
+	shutdownOnce sync.Once
+}
+
+type ArenaInfo struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	GameStatus   GameStatus `json:"game_status"`
+	GameDuration int        `json:"game_duration"`
+	Players      int        `json:"players"`
+}
+
+func newArena(ctx context.Context, app pitaya.Pitaya, db *db.Client, cfg *config.Config, arena config.Arena, sdkClient *zecreyface.Client) (*Arena, error) {
+	arenaCfg, err := cfg.ForArena(arena)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := LoadMapLayout(arenaCfg.Map); err != nil {
+		return nil, err
+	}
+	if err := validBallCollisions(arenaCfg.BallCollisions); err != nil {
+		return nil, err
+	}
+	if err := validTieBreak(arenaCfg.Win.TieBreak); err != nil {
+		return nil, err
+	}
+	if err := validRestartPolicy(arenaCfg.Checkpoint.OnRestart); err != nil {
+		return nil, err
+	}
+	if err := validShutdownPolicy(arenaCfg.Shutdown.Policy); err != nil {
+		return nil, err
+	}
+	if err := validBots(arenaCfg.Bots); err != nil {
+		return nil, err
+	}
+	a := &Arena{
+		ID:        arena.ID,
+		Name:      arena.Name,
+		ctx:       ctx,
+		app:       app,
+		cfg:       arenaCfg,
+		db:        db,
+		gameGroup: config.GameGroupName(arena.ID),
+		chatGroup: config.ChatGroupName(arena.ID),
+	}
+	if err := app.GroupCreate(context.Background(), a.gameGroup); err != nil {
+		return nil, err
+	}
+	if err := app.GroupCreate(context.Background(), a.chatGroup); err != nil {
+		return nil, err
+	}
+	a.game = NewGame(ctx, arenaCfg, db, sdkClient, a.onGameStart, a.onGameStop, a.onCampVotesChange)
+	a.game.arenaID = arena.ID
+	a.game.onPhaseChange = a.onPhaseChange
+	a.game.onPause = a.onPause
+	return a, nil
+}
+
+func (a *Arena) Game() *Game {
+	return a.game
+}
+
+func (a *Arena) Config() *config.Config {
+	return a.cfg
+}
+
+func (a *Arena) GameGroup() string {
+	return a.gameGroup
+}
+
+func (a *Arena) ChatGroup() string {
+	return a.chatGroup
+}
+
+func (a *Arena) Info() ArenaInfo {
+	snap := a.game.Snapshot()
+	return ArenaInfo{
+		ID:           a.ID,
+		Name:         a.Name,
+		GameStatus:   snap.GameStatus,
+		GameDuration: snap.Duration,
+		Players:      len(snap.Players),
+	}
+}
+
+// run starts the game and broadcasts its frames until ctx is done
+func (a *Arena) run() {
+	frameChan := a.game.start()
+	go func() {
+		for {
+			select {
+			//case nextRoundChan := <-r.game.stopSignalChan:
+			//	<-nextRoundChan
+			case <-a.ctx.Done():
+				return
+			case f := <-frameChan:
+				err := a.app.GroupBroadcast(context.Background(), a.cfg.FrontendType, a.gameGroup, f.Route(), GameUpdate{Data: f.Data})
+				if err != nil {
+					zap.L().Error("broadcast frame failed", zap.String("arena", a.ID), zap.String("route", f.Route()), zap.Error(err))
+				}
+				if f.Effects != nil {
+					err := a.app.GroupBroadcast(context.Background(), a.cfg.FrontendType, a.gameGroup, effectsRoute, GameUpdate{Data: f.Effects})
+					if err != nil {
+						zap.L().Error("broadcast effects failed", zap.String("arena", a.ID), zap.Error(err))
+					}
+				}
+				if len(f.Collisions) > 0 {
+					err := a.app.GroupBroadcast(context.Background(), a.cfg.FrontendType, a.gameGroup, collisionsRoute, BallCollisions{Collisions: f.Collisions})
+					if err != nil {
+						zap.L().Error("broadcast collisions failed", zap.String("arena", a.ID), zap.Error(err))
+					}
+				}
+				if len(f.Events) > 0 {
+					err := a.app.GroupBroadcast(context.Background(), a.cfg.FrontendType, a.gameGroup, eventsRoute, GameEvents{Ticks: f.Events})
+					if err != nil {
+						zap.L().Error("broadcast events failed", zap.String("arena", a.ID), zap.Error(err))
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (a *Arena) mapInfo(replay bool) MapInfo {
+	return layoutInfo(a.game.layout, replay)
+}
+
+func layoutInfo(l *MapLayout, replay bool) MapInfo {
+	return MapInfo{
+		Row:        uint32(l.Row),
+		Column:     uint32(l.Column),
+		CellWidth:  uint32(l.CellWidth),
+		CellHeight: uint32(l.CellHeight),
+		Tiles:      l.SerializeTiles(),
+		BonusValue: uint32(l.BonusValue),
+
+		Camps:  Camps,
+		Item:   AllItems,
+		Replay: replay,
+	}
+}
+
+// onJoin room
+func (a *Arena) onJoin(ctx context.Context, replay bool) {
+	mi := a.mapInfo(replay)
+	mi.Players, _ = a.db.Player.List(a.game.Snapshot().Players...)
+	a.app.GroupBroadcast(ctx, a.cfg.FrontendType, a.gameGroup, "onJoin", mi)
+}
+
+func (a *Arena) onGameStart(ctx context.Context) {
+	info, _ := a.game.GetGameInfo()
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.gameGroup, "onGameStart", info)
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.chatGroup, "onGameStart", info)
+	a.onJoin(ctx, true)
+}
+
+func (a *Arena) onGameStop(ctx context.Context, stop GameStop) {
+	//fmt.Println("winner info ", stop)
+	a.app.GroupBroadcast(ctx, a.cfg.FrontendType, a.gameGroup, "onGameStop", stop)
+	a.app.GroupBroadcast(ctx, a.cfg.FrontendType, a.chatGroup, "onGameStop", stop)
+}
+
+func (a *Arena) onPhaseChange(pc PhaseChange) {
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.gameGroup, phaseRoute, pc)
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.chatGroup, phaseRoute, pc)
+}
+
+func (a *Arena) onPause(route string, gp GamePause) {
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.gameGroup, route, gp)
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.chatGroup, route, gp)
+}
+
+func (a *Arena) onCampVotesChange(camp Camp, votes int32) {
+	a.app.GroupBroadcast(a.ctx, a.cfg.FrontendType, a.chatGroup, "onCampVotesChange", CampVotesChange{
+		Camp:  camp,
+		Votes: votes,
+	})
+}
+
+type Arenas struct {
+	list []*Arena
+	byID map[string]*Arena
+}
+
+func newArenas(ctx context.Context, app pitaya.Pitaya, db *db.Client, cfg *config.Config, sdkClient *zecreyface.Client) (*Arenas, error) {
+	as := &Arenas{byID: map[string]*Arena{}}
+	for _, arena := range cfg.Arenas {
+		a, err := newArena(ctx, app, db, cfg, arena, sdkClient)
+		if err != nil {
+			return nil, fmt.Errorf("arena %s: %w", arena.ID, err)
+		}
+		as.list = append(as.list, a)
+		as.byID[a.ID] = a
+	}
+	return as, nil
+}
+
+func (as *Arenas) Get(id string) (*Arena, bool) {
+	a, ok := as.byID[id]
+	return a, ok
+}
+
+// Default is the first configured arena, sessions that never picked one play there
+func (as *Arenas) Default() *Arena {
+	return as.list[0]
+}
+
+func (as *Arenas) List() []*Arena {
+	return as.list
+}
+
+// FromSession returns the arena the session joined, or the default one
+func (as *Arenas) FromSession(s session.Session) *Arena {
+	if a, ok := as.Get(s.String(arenaSessionKey)); ok {
+		return a
+	}
+	return as.Default()
+}
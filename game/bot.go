@@ -0,0 +1,175 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"go.uber.org/zap"
+)
+
+const (
+	BotsMinPerCamp = "min_per_camp" // every camp gets at least min_per_camp balls
+	BotsBalance    = "balance"      // every camp gets as many balls as the biggest one
+
+	BotPassive    = "passive"
+	BotNormal     = "normal"
+	BotAggressive = "aggressive"
+
+	// bots have ids between botIDBase and splitBallIDBase, 2^16 per camp
+	botIDBase     = 1 << 62
+	botsPerCampID = 1 << 16
+)
+
+// botProfile is how a bot picks when and where to join
+type botProfile struct {
+	delay   int  // seconds a camp stays short of balls before a bot joins it
+	weakest bool // join the short camp holding the fewest cells rather than the one with the fewest balls
+}
+
+var botProfiles = map[string]botProfile{
+	BotPassive:    {delay: 10},
+	BotNormal:     {delay: 5},
+	BotAggressive: {delay: 1, weakest: true},
+}
+
+func validBots(cfg config.BotConfig) error {
+	switch cfg.Mode {
+	case "", BotsBalance:
+	case BotsMinPerCamp:
+		if cfg.MinPerCamp <= 0 {
+			return fmt.Errorf("bots min_per_camp must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown bots mode %q", cfg.Mode)
+	}
+	if _, ok := botProfiles[cfg.Profile]; !ok && cfg.Profile != "" {
+		return fmt.Errorf("unknown bots profile %q", cfg.Profile)
+	}
+	return nil
+}
+
+// IsBotID tells whether the id belongs to a bot, players can't use these ids
+func IsBotID(id uint64) bool {
+	return id >= botIDBase && id < splitBallIDBase
+}
+
+func (g *Game) botProfile() botProfile {
+	if p, ok := botProfiles[g.cfg.Bots.Profile]; ok {
+		return p
+	}
+	return botProfiles[BotNormal]
+}
+
+// campBalls counts the balls of each camp, split balls left out
+func (g *Game) campBalls() (players, bots map[Camp]int) {
+	players, bots = map[Camp]int{}, map[Camp]int{}
+	g.Players.Range(func(key, value interface{}) bool {
+		p, ok := value.(*Player)
+		switch {
+		case !ok || p.ID >= splitBallIDBase:
+		case IsBotID(p.ID):
+			bots[p.Camp]++
+		default:
+			players[p.Camp]++
+		}
+		return true
+	})
+	return players, bots
+}
+
+// fillBots adds a bot to a camp short of balls, at most one a second. It runs in the lobby and
+// during the round, as long as a player is there.
+func (g *Game) fillBots() {
+	cfg := g.cfg.Bots
+	if cfg.Mode == "" {
+		return
+	}
+	g.botTicks++
+	if g.botTicks%uint32(g.tickRate()) != 0 {
+		return
+	}
+	lobby := g.scheduled() && g.phase == PhaseLobby
+	players, bots := g.campBalls()
+	if (g.GameStatus != GameRunning && !lobby) || len(players) == 0 {
+		g.botWait = nil
+		return
+	}
+	total := 0
+	for _, n := range bots {
+		total += n
+	}
+	if cfg.MaxBots > 0 && total >= cfg.MaxBots {
+		return
+	}
+
+	target := cfg.MinPerCamp
+	if cfg.Mode == BotsBalance {
+		target = 0
+		for _, c := range Camps {
+			if n := players[c.Camp] + bots[c.Camp]; n > target {
+				target = n
+			}
+		}
+	}
+	if g.botWait == nil {
+		g.botWait = map[Camp]int{}
+	}
+	profile := g.botProfile()
+	scores := g.campScores()
+	pick, found := Empty, false
+	for _, c := range Camps {
+		n := players[c.Camp] + bots[c.Camp]
+		if n >= target {
+			delete(g.botWait, c.Camp)
+			continue
+		}
+		g.botWait[c.Camp]++
+		if g.botWait[c.Camp] <= profile.delay {
+			continue
+		}
+		if !found {
+			pick, found = c.Camp, true
+			continue
+		}
+		best := players[pick] + bots[pick]
+		if profile.weakest && scores[c.Camp] < scores[pick] || !profile.weakest && n < best {
+			pick = c.Camp
+		}
+	}
+	if found {
+		g.addBot(pick)
+		delete(g.botWait, pick)
+	}
+}
+
+// addBot votes for the camp like a player would
+func (g *Game) addBot(camp Camp) {
+	base := botIDBase + uint64(camp)*botsPerCampID
+	for n := uint64(1); n < botsPerCampID; n++ {
+		if _, ok := g.Players.Load(base + n); ok {
+			continue
+		}
+		if g.db != nil {
+			bot := &model.Player{PlayerID: base + n, Name: fmt.Sprintf("Bot %s %d", CampTagMap[camp], n), Bot: true}
+			g.persist(func() {
+				if err := g.db.Player.Create(bot); err != nil {
+					zap.L().Error("failed to create bot", zap.Uint64("player_id", bot.PlayerID), zap.Error(err))
+				}
+			})
+		}
+		g.AddPlayer(base+n, camp)
+		return
+	}
+}
+
+// withoutBots drops the stats of the bots, they're never ranked
+func withoutBots(stats []model.PlayerGameStats) []model.PlayerGameStats {
+	players := stats[:0:0]
+	for _, s := range stats {
+		if !IsBotID(s.PlayerID) {
+			players = append(players, s)
+		}
+	}
+	return players
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+// runSeconds calls fillBots as the tick goroutine would for that many seconds
+func runSeconds(g *Game, seconds int) {
+	for i := 0; i < seconds*g.tickRate(); i++ {
+		g.fillBots()
+	}
+}
+
+func TestBotsBalance(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, Bots: config.BotConfig{Mode: BotsBalance, MaxBots: 20}})
+	g.GameStatus = GameRunning
+
+	// no bot plays alone
+	runSeconds(g, 20)
+	if players, bots := g.campBalls(); len(players) != 0 || len(bots) != 0 {
+		t.Fatalf("bots joined an empty round: %v", bots)
+	}
+
+	g.AddPlayer(1, BTC)
+	g.AddPlayer(2, BTC)
+	// the normal profile waits 5 seconds before the first bot, then adds one a second
+	runSeconds(g, 5)
+	if _, bots := g.campBalls(); len(bots) != 0 {
+		t.Fatalf("bots joined before the delay: %v", bots)
+	}
+	runSeconds(g, 30)
+	players, bots := g.campBalls()
+	for _, c := range Camps {
+		if n := players[c.Camp] + bots[c.Camp]; n != 2 {
+			t.Fatalf("camp %s has %d balls, want 2", c.Tag, n)
+		}
+	}
+	if bots[BTC] != 0 {
+		t.Fatalf("BTC got %d bots", bots[BTC])
+	}
+	for _, c := range Camps {
+		if n, want := g.campVoteCount(c.Camp), int32(players[c.Camp]); n != want {
+			t.Fatalf("camp %s has %d votes, want %d", c.Tag, n, want)
+		}
+	}
+	g.Players.Range(func(key, value interface{}) bool {
+		if id := key.(uint64); id != 1 && id != 2 && !IsBotID(id) {
+			t.Fatalf("bot id %d out of range", id)
+		}
+		return true
+	})
+}
+
+func TestBotsMinPerCamp(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, Bots: config.BotConfig{Mode: BotsMinPerCamp, MinPerCamp: 3, MaxBots: 4, Profile: BotAggressive}})
+	g.GameStatus = GameRunning
+	g.AddPlayer(1, ETH)
+	runSeconds(g, 60)
+	total := 0
+	_, bots := g.campBalls()
+	for _, n := range bots {
+		total += n
+	}
+	if total != 4 {
+		t.Fatalf("%d bots joined, max is 4", total)
+	}
+	if err := validBots(config.BotConfig{Mode: BotsMinPerCamp}); err == nil {
+		t.Fatal("min_per_camp without a minimum accepted")
+	}
+	if err := validBots(config.BotConfig{Mode: BotsBalance, Profile: "reckless"}); err == nil {
+		t.Fatal("unknown profile accepted")
+	}
+}
@@ -1,7 +1,12 @@
 package game
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/model"
 )
 
 const (
@@ -12,6 +17,7 @@ const (
 
 type Camp uint8 // should convert to int4 when transfered to client
 
+// ids of the default camps, the camps really played come from the config
 const (
 	Empty Camp = iota
 	BTC
@@ -26,26 +32,30 @@ const (
 	BNBTag   = "BNB"
 	AVAXTag  = "AVAX"
 	MATICTag = "MATIC"
+
+	maxCamps = 1<<sizeOfCellStateBits - 1 // a cell holds 4 bits and 0 is Empty
 )
 
+// CampInfo is a camp of the registry, sent to the clients when they join
+type CampInfo struct {
+	Camp     Camp     `json:"id"`
+	Tag      string   `json:"tag"`
+	Name     string   `json:"name"`
+	Color    string   `json:"color"`
+	Icon     string   `json:"icon"`
+	Keywords []string `json:"keywords"`
+
+	spawn *[2]int
+}
+
 var (
-	CampTagMap = map[Camp]string{
-		Empty: EmptyTag,
-		BTC:   BTCTag,
-		ETH:   ETHTag,
-		BNB:   BNBTag,
-		AVAX:  AVAXTag,
-		MATIC: MATICTag,
-	}
+	CampTagMap        = map[Camp]string{}
+	CampTagMapReverse = map[string]Camp{}
 
-	CampTagMapReverse = map[string]Camp{
-		EmptyTag: Empty,
-		BTCTag:   BTC,
-		ETHTag:   ETH,
-		BNBTag:   BNB,
-		AVAXTag:  AVAX,
-		MATICTag: MATIC,
-	}
+	// Camps is the registry of the camps played, ordered by id
+	Camps []CampInfo
+
+	collisionTags = map[Camp][]string{}
 
 	CampSizeMap = map[Camp][2]int{
 		Empty: {0, 0},
@@ -57,23 +67,95 @@ var (
 	}
 )
 
-func getCollisionTags(camp Camp) (retval []string) {
-	switch camp {
-	case BTC:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case ETH:
-		retval = []string{CampTagMap[BNB], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case BNB:
-		retval = []string{CampTagMap[ETH], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case AVAX:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[BTC], CampTagMap[MATIC], CampTagMap[Empty]}
-	case MATIC:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[Empty]}
-	default:
-		retval = []string{CampTagMap[BTC], CampTagMap[ETH], CampTagMap[BNB], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
+func init() {
+	if err := SetCamps(config.DefaultCamps); err != nil {
+		panic(err)
+	}
+}
+
+// SetCamps replaces the registry, it must be called before the games are created
+func SetCamps(camps []config.CampConfig) error {
+	if len(camps) < 2 || len(camps) > maxCamps {
+		return fmt.Errorf("%d camps, there must be between 2 and %d", len(camps), maxCamps)
+	}
+	tagMap := map[Camp]string{Empty: EmptyTag}
+	tagMapReverse := map[string]Camp{EmptyTag: Empty}
+	var infos []CampInfo
+	for _, c := range camps {
+		camp := Camp(c.ID)
+		if camp == Empty || camp > maxCamps {
+			return fmt.Errorf("camp %s has id %d, it must be between 1 and %d", c.Tag, c.ID, maxCamps)
+		}
+		if _, ok := tagMap[camp]; ok {
+			return fmt.Errorf("camp id %d is used twice", c.ID)
+		}
+		if c.Tag == "" {
+			return fmt.Errorf("camp %d has no tag", c.ID)
+		}
+		if _, ok := tagMapReverse[c.Tag]; ok {
+			return fmt.Errorf("camp tag %s is used twice", c.Tag)
+		}
+		tagMap[camp] = c.Tag
+		tagMapReverse[c.Tag] = camp
+		infos = append(infos, CampInfo{
+			Camp:     camp,
+			Tag:      c.Tag,
+			Name:     c.Name,
+			Color:    c.Color,
+			Icon:     c.Icon,
+			Keywords: append([]string{c.Tag}, c.Keywords...),
+			spawn:    c.Spawn,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Camp < infos[j].Camp })
+
+	// a ball bounces on every cell it doesn't own
+	tags := map[Camp][]string{}
+	for _, camp := range append([]Camp{Empty}, campIDs(infos)...) {
+		var t []string
+		for _, other := range infos {
+			if other.Camp != camp {
+				t = append(t, other.Tag)
+			}
+		}
+		tags[camp] = append(t, EmptyTag, EdgeTag, WallTag, HoleTag, ItemTag)
+	}
+
+	CampTagMap, CampTagMapReverse, Camps, collisionTags = tagMap, tagMapReverse, infos, tags
+	return nil
+}
+
+func campIDs(infos []CampInfo) []Camp {
+	ids := make([]Camp, 0, len(infos))
+	for _, c := range infos {
+		ids = append(ids, c.Camp)
+	}
+	return ids
+}
+
+// CampModels are the camps of the registry as stored in the camps table
+func CampModels() []model.Camp {
+	var camps []model.Camp
+	for _, c := range Camps {
+		camps = append(camps, model.Camp{ID: uint8(c.Camp), Name: c.Name, ShortName: c.Tag, Color: c.Color, Icon: c.Icon})
+	}
+	return camps
+}
+
+// CampModelIDs are the ids of the camps of the registry in the camps table
+func CampModelIDs() []uint8 {
+	ids := make([]uint8, 0, len(Camps))
+	for _, c := range Camps {
+		ids = append(ids, uint8(c.Camp))
 	}
-	retval = append(retval, EdgeTag, ItemTag)
-	return
+	return ids
+}
+
+func getCollisionTags(camp Camp) []string {
+	if tags, ok := collisionTags[camp]; ok {
+		return tags
+	}
+	return collisionTags[Empty]
 }
 
 func removeCampTags(tags []string) []string {
@@ -87,13 +169,11 @@ func removeCampTags(tags []string) []string {
 }
 
 //初始化阵营十字位置
-func initCamp(x, y int) Camp {
+func (l *MapLayout) initCamp(x, y int) Camp {
 	camp := Empty
-	for c := range CampTagMap {
-		if c == Empty {
-			continue
-		}
-		cx, cy := c.CenterCellIndex(mapRow, mapColumn)
+	for _, info := range Camps {
+		c := info.Camp
+		cx, cy := l.Spawn(c)
 		if (x == cx && y == cy) || (x-1 == cx && y == cy) || (x+1 == cx && y == cy) || (x == cx && y-1 == cy) || (x == cx && y+1 == cy) {
 			camp = c
 			break
@@ -102,28 +182,35 @@ func initCamp(x, y int) Camp {
 	return camp
 }
 
-//阵营中心位置
+//阵营中心位置, the default spawns of the camps 1 to 5 are laid out on a 40x30 map and scaled to the real one
 func (c Camp) CenterCellIndex(row, col int) (int, int) {
+	scale := func(x, y int) (int, int) {
+		return x * col / defaultMapColumn, y * row / defaultMapRow
+	}
 	switch c {
 	case ETH:
-		return 35, 25
+		return scale(35, 25)
 	case BNB:
-		return 20, 4
+		return scale(20, 4)
 	case AVAX:
-		return 4, 4
+		return scale(4, 4)
 	case MATIC:
-		return 35, 4
+		return scale(35, 4)
 	case BTC:
-		return 4, 25
+		return scale(4, 25)
 	default:
 		return col / 5, row / 5
 	}
 }
 
+// DecideCamp returns the camp whose keyword appears first in the registry order, Empty when none does
 func DecideCamp(msg string) Camp {
-	for _, tag := range CampTagMap {
-		if strings.Contains(strings.ToUpper(msg), tag) {
-			return CampTagMapReverse[tag]
+	msg = strings.ToUpper(msg)
+	for _, c := range Camps {
+		for _, keyword := range c.Keywords {
+			if strings.Contains(msg, strings.ToUpper(keyword)) {
+				return c.Camp
+			}
 		}
 	}
 	return Empty
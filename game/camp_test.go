@@ -0,0 +1,55 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestCustomCamps(t *testing.T) {
+	defer SetCamps(config.DefaultCamps)
+	camps := []config.CampConfig{
+		{ID: 1, Tag: "SOL", Name: "Solana", Spawn: &[2]int{5, 5}, Keywords: []string{"sunny"}},
+		{ID: 2, Tag: "ADA", Name: "Cardano", Spawn: &[2]int{20, 15}},
+		{ID: 9, Tag: "DOGE", Name: "Dogecoin", Spawn: &[2]int{30, 5}},
+	}
+	if err := SetCamps(camps); err != nil {
+		t.Fatal(err)
+	}
+	if DecideCamp("go doge") != 9 || DecideCamp("so Sunny") != 1 || DecideCamp("btc") != Empty {
+		t.Fatal("votes matched the wrong camps")
+	}
+	tags := getCollisionTags(9)
+	for _, tag := range tags {
+		if tag == "DOGE" {
+			t.Fatal("a ball collides with its own camp")
+		}
+	}
+	if len(tags) != 7 {
+		t.Fatalf("collision tags %v", tags)
+	}
+
+	g := newTestGame(&config.Config{FPS: 30})
+	if c := g.Map.Cells[5*g.Map.Column+30]; c != 9 {
+		t.Fatalf("DOGE spawn cell is %d", c)
+	}
+	g.GameStatus = GameRunning
+	g.AddPlayer(1, 9)
+	for i := 0; i < 300; i++ {
+		g.Update()
+	}
+	if winner, _ := g.GetWinner(); winner == Empty {
+		t.Fatal("no winner")
+	}
+
+	for _, bad := range [][]config.CampConfig{
+		{{ID: 1, Tag: "SOL"}},
+		{{ID: 1, Tag: "SOL"}, {ID: 1, Tag: "ADA"}},
+		{{ID: 1, Tag: "SOL"}, {ID: 16, Tag: "ADA"}},
+		{{ID: 1, Tag: "SOL"}, {ID: 2, Tag: "SOL"}},
+	} {
+		if err := SetCamps(bad); err == nil {
+			t.Fatalf("%+v should be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"github.com/solarlune/resolv"
+	"go.uber.org/zap"
+)
+
+const (
+	checkpointVersion = 3
+
+	RestartResume = "resume" // the round goes on from its last checkpoint
+	RestartVoid   = "void"   // the round is voided and a new one starts
+
+	defaultCheckpointInterval = 5 // seconds
+)
+
+func validRestartPolicy(policy string) error {
+	switch policy {
+	case "", RestartResume, RestartVoid:
+		return nil
+	}
+	return fmt.Errorf("unknown checkpoint on_restart %q", policy)
+}
+
+// checkpoint is everything a round needs to go on after a restart, the replay is stored in parts next to it
+type checkpoint struct {
+	Version      int
+	GameID       uint
+	Seed         int64
+	Draws        uint64 // numbers drawn from the seeded source
+	Tick         uint32
+	RoundTicks   uint32
+	Paused       bool
+	Column       int
+	Row          int
+	Cells        []Camp
+	Balls        []checkpointBall
+	Items        []checkpointItem
+	NextBallID   uint64
+	NextItemID   uint32
+	SubRoundWins []Camp
+	ScoreReached map[Camp][]uint32
+	CampVotes    map[Camp]int32
+	ToRewardName string
+	Stats        []*PlayerStats
+}
+
+// checkpointQueue holds what the job goroutine has to write, only the latest checkpoint is kept
+// but every replay part is
+type checkpointQueue struct {
+	mu     sync.Mutex
+	queued bool
+	row    *gameRow // the round of cp and parts
+	cp     *checkpoint
+	parts  []model.CheckpointReplay
+}
+
+// savedReplay is how much of the replay of the round the checkpoints hold
+type savedReplay struct {
+	votes, items int
+	parts        uint32
+}
+
+type checkpointBall struct {
+	ID        uint64
+	Camp      Camp
+	X, Y      float64
+	R         int
+	Vx, Vy    float64
+	Effects   []Effect
+	Owner     uint64
+	SpawnTick uint32
+}
+
+type checkpointItem struct {
+	Id         uint32
+	Type       ItemType
+	X, Y       float64
+	ExpireTick uint32
+}
+
+func (g *Game) checkpointTicks() uint32 {
+	switch {
+	case g.cfg.Checkpoint.Interval < 0:
+		return 0
+	case g.cfg.Checkpoint.Interval == 0:
+		return g.secondsToTicks(defaultCheckpointInterval)
+	}
+	return g.secondsToTicks(g.cfg.Checkpoint.Interval)
+}
+
+func (g *Game) captureCheckpoint() *checkpoint {
+	cp := &checkpoint{
+		Version:      checkpointVersion,
+		GameID:       g.gameID(),
+		Seed:         g.seed,
+		Draws:        g.rngSource.draws,
+		Tick:         g.tick,
+		RoundTicks:   g.roundTicks,
+		Paused:       g.GameStatus == GamePaused,
+		Column:       g.Map.Column,
+		Row:          g.Map.Row,
+		Cells:        append([]Camp(nil), g.Map.Cells...),
+		NextBallID:   g.nextBallID,
+		NextItemID:   g.nextItemID,
+		SubRoundWins: append([]Camp(nil), g.subRoundWins...),
+		ScoreReached: map[Camp][]uint32{},
+		CampVotes:    map[Camp]int32{},
+		ToRewardName: g.toRewardName,
+	}
+	for c, ticks := range g.scoreReached {
+		cp.ScoreReached[c] = append([]uint32(nil), ticks...)
+	}
+	g.rangePlayers(func(p *Player) bool {
+		effects := append([]Effect(nil), p.effects...)
+		cp.Balls = append(cp.Balls, checkpointBall{ID: p.ID, Camp: p.Camp, X: p.playerObj.X, Y: p.playerObj.Y, R: p.R, Vx: p.Vx, Vy: p.Vy, Effects: effects, Owner: p.owner, SpawnTick: p.spawnTick})
+		return true
+	})
+	g.Items.Range(func(key, value interface{}) bool {
+		if item, ok := value.(*ItemObject); ok {
+			cp.Items = append(cp.Items, checkpointItem{Id: item.Id, Type: item.Item.Type, X: item.X, Y: item.Y, ExpireTick: item.ExpireTick})
+		}
+		return true
+	})
+	for _, c := range Camps {
+		if n := g.campVoteCount(c.Camp); n > 0 {
+			cp.CampVotes[c.Camp] = n
+		}
+	}
+	for _, s := range g.stats {
+		s := *s
+		stolen := s.StolenFrom
+		s.StolenFrom = map[Camp]int{}
+		for c, n := range stolen {
+			s.StolenFrom[c] = n
+		}
+		cp.Stats = append(cp.Stats, &s)
+	}
+	return cp
+}
+
+// replayPart is the replay recorded since the last checkpoint, nil when there's nothing new
+func (g *Game) replayPart() *Replay {
+	if g.replay == nil {
+		return nil
+	}
+	r, saved := g.replay, &g.savedReplay
+	if len(r.Votes) == saved.votes && len(r.Items) == saved.items {
+		return nil
+	}
+	part := &Replay{
+		Seed:  r.Seed,
+		Votes: append([]ReplayVote(nil), r.Votes[saved.votes:]...),
+		Items: append([]ReplayItem(nil), r.Items[saved.items:]...),
+	}
+	saved.votes, saved.items = len(r.Votes), len(r.Items)
+	return part
+}
+
+// joinReplayParts puts the replay of a round back together from its parts
+func joinReplayParts(seed int64, parts []model.CheckpointReplay) (*Replay, error) {
+	replay := newReplay(seed)
+	for _, m := range parts {
+		part, err := DecodeReplay(&model.Replay{Seed: seed, Data: m.Data})
+		if err != nil {
+			return nil, err
+		}
+		replay.Votes = append(replay.Votes, part.Votes...)
+		replay.Items = append(replay.Items, part.Items...)
+	}
+	return replay, nil
+}
+
+// saveCheckpoint stores the state of the running round every checkpoint interval
+func (g *Game) saveCheckpoint() {
+	if n := g.checkpointTicks(); n > 0 && g.tick%n == 0 {
+		g.writeCheckpoint()
+	}
+}
+
+// writeCheckpoint captures the round and queues it for the job goroutine, which encodes and writes it
+func (g *Game) writeCheckpoint() {
+	if g.db == nil || g.dbGame == nil || g.checkpointTicks() == 0 {
+		return
+	}
+	cp := g.captureCheckpoint()
+	var part *model.CheckpointReplay
+	if r := g.replayPart(); r != nil {
+		part = &model.CheckpointReplay{ArenaID: g.arenaID, GameID: cp.GameID, Seq: g.savedReplay.parts, Data: r.Serialize()}
+		g.savedReplay.parts++
+	}
+	q := &g.checkpoints
+	q.mu.Lock()
+	// the parts left by a round that ended are of no use anymore
+	parts := q.parts
+	if q.row != g.row {
+		parts = nil
+	}
+	if part != nil {
+		parts = append(parts, *part)
+	}
+	q.row, q.cp, q.parts = g.row, cp, parts
+	queued := q.queued
+	q.queued = true
+	q.mu.Unlock()
+	if !queued {
+		g.persist(g.flushCheckpoint)
+	}
+}
+
+// flushCheckpoint writes the latest checkpoint with the replay parts on the job goroutine, the parts
+// wait for the next checkpoint when it fails
+func (g *Game) flushCheckpoint() {
+	q := &g.checkpoints
+	q.mu.Lock()
+	row, cp, parts := q.row, q.cp, q.parts
+	q.cp, q.parts, q.queued = nil, nil, false
+	q.mu.Unlock()
+	if row == nil || row.id == 0 {
+		// the row of the round couldn't be created
+		return
+	}
+	cp.GameID = row.id
+	for i := range parts {
+		parts[i].GameID = row.id
+	}
+	data, err := json.Marshal(cp)
+	if err == nil {
+		err = g.db.Checkpoint.Save(&model.Checkpoint{ArenaID: g.arenaID, GameID: cp.GameID, Tick: cp.Tick, Data: data}, parts)
+	}
+	if err != nil {
+		zap.L().Error("failed to save checkpoint", zap.Uint("game_id", cp.GameID), zap.Error(err))
+		q.mu.Lock()
+		if q.row == row {
+			q.parts = append(parts[:len(parts):len(parts)], q.parts...)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// deleteCheckpoint removes the checkpoint of the round, the job goroutine calls it once the round is saved
+func (g *Game) deleteCheckpoint(gameID uint) {
+	if g.db == nil {
+		return
+	}
+	if err := g.db.Checkpoint.Delete(g.arenaID, gameID); err != nil {
+		zap.L().Error("failed to delete checkpoint", zap.String("arena", g.arenaID), zap.Uint("game_id", gameID), zap.Error(err))
+	}
+}
+
+// Restart is how the round cut by the last restart of the server went on, sent with the game info until
+// the next round
+type Restart struct {
+	GameRound uint `json:"game_round"` // 0 when only rounds without a checkpoint were voided
+	Voided    bool `json:"voided"`     // the round goes on otherwise, paused if it was
+}
+
+// restoreCheckpoint picks up the round cut by a restart, true when it goes on. The rounds of the arena
+// left without a result are voided otherwise, their votes never score.
+func (g *Game) restoreCheckpoint() bool {
+	if g.db == nil {
+		return false
+	}
+	resumed, cut := uint(0), uint(0)
+	if m, err := g.db.Checkpoint.Get(g.arenaID); err == nil {
+		cut = m.GameID
+		if g.cfg.Checkpoint.OnRestart != RestartVoid {
+			if err := g.resume(m); err != nil {
+				zap.L().Error("failed to resume round, voiding it", zap.Uint("game_id", m.GameID), zap.Error(err))
+			} else {
+				resumed = m.GameID
+			}
+		}
+		if resumed == 0 {
+			g.deleteCheckpoint(m.GameID)
+		}
+	}
+	n, err := g.db.Game.VoidUnfinished(g.arenaID, resumed)
+	if err != nil {
+		zap.L().Error("failed to void unfinished rounds", zap.String("arena", g.arenaID), zap.Error(err))
+	} else if n > 0 {
+		zap.L().Info("voided unfinished rounds", zap.String("arena", g.arenaID), zap.Int64("rounds", n))
+	}
+	if resumed == 0 {
+		if cut != 0 || n > 0 {
+			g.restart = &Restart{GameRound: cut, Voided: true}
+		}
+		return false
+	}
+	zap.L().Info("round resumed from checkpoint", zap.String("arena", g.arenaID), zap.Uint("game_id", resumed), zap.Uint32("tick", g.tick))
+	if g.scheduled() {
+		g.setPhase(PhaseRunning)
+	}
+	// nobody is connected yet, the clients learn it with the game info when they join
+	g.restart = &Restart{GameRound: resumed}
+	return true
+}
+
+func (g *Game) resume(m *model.Checkpoint) error {
+	var cp checkpoint
+	if err := json.Unmarshal(m.Data, &cp); err != nil {
+		return err
+	}
+	dbGame, err := g.db.Game.Get(cp.GameID)
+	if err != nil {
+		return err
+	}
+	parts, err := g.db.Checkpoint.ReplayParts(g.arenaID, cp.GameID)
+	if err != nil {
+		return err
+	}
+	if err := g.applyCheckpoint(&cp, parts); err != nil {
+		return err
+	}
+	g.dbGame, g.row = dbGame, &gameRow{id: dbGame.ID}
+	g.dbGame.EndTime = time.Now().Add(g.remaining())
+	g.updateGame()
+	return nil
+}
+
+// applyCheckpoint puts a reset game in the state of the checkpoint, parts are the replay of the round
+func (g *Game) applyCheckpoint(cp *checkpoint, parts []model.CheckpointReplay) error {
+	if cp.Version != checkpointVersion {
+		return fmt.Errorf("checkpoint version %d", cp.Version)
+	}
+	if cp.Column != g.Map.Column || cp.Row != g.Map.Row || len(cp.Cells) != len(g.Map.Cells) {
+		return errors.New("checkpoint of another map")
+	}
+	for _, b := range cp.Balls {
+		if _, ok := CampTagMap[b.Camp]; !ok {
+			return fmt.Errorf("checkpoint ball of unknown camp %d", b.Camp)
+		}
+	}
+	replay, err := joinReplayParts(cp.Seed, parts)
+	if err != nil {
+		return err
+	}
+	g.seedRound(cp.Seed)
+	g.rngSource.skip(cp.Draws)
+	g.replay = replay
+	g.savedReplay = savedReplay{votes: len(replay.Votes), items: len(replay.Items), parts: uint32(len(parts))}
+	for i, c := range cp.Cells {
+		if g.cells[i] != nil {
+			g.captureCell(i%g.Map.Column, i/g.Map.Column, c)
+		}
+	}
+	for _, b := range cp.Balls {
+		p := g.addBall(b.ID, b.Camp, b.X, b.Y, b.Vx, b.Vy)
+		p.effects = b.Effects
+		p.owner = b.Owner
+		p.spawnTick = b.SpawnTick
+		if b.R != p.R {
+			p.resize(b.R)
+			p.playerObj.X, p.playerObj.Y = b.X, b.Y
+			p.playerObj.Update()
+		}
+	}
+	// the items go back in the space in the order they spawned, like in the round
+	sort.Slice(cp.Items, func(i, j int) bool { return cp.Items[i].Id < cp.Items[j].Id })
+	for _, it := range cp.Items {
+		kind, ok := ItemMap[it.Type]
+		if !ok {
+			continue
+		}
+		item := &ItemObject{Id: it.Id, X: it.X, Y: it.Y, Item: kind, ExpireTick: it.ExpireTick}
+		item.obj = resolv.NewObject(it.X, it.Y, float64(2*itemPixelR), float64(2*itemPixelR), ItemTag, ItemTagMap[kind.Type], itemIdToTag(item.Id))
+		g.space.Add(item.obj)
+		g.Items.Store(item.Id, item)
+	}
+	g.campVotes = sync.Map{}
+	for c, n := range cp.CampVotes {
+		votes := n
+		g.campVotes.Store(c, &votes)
+	}
+	g.tick = cp.Tick
+	g.roundTicks = cp.RoundTicks
+	g.nextBallID = cp.NextBallID
+	g.nextItemID = cp.NextItemID
+	g.subRoundWins = cp.SubRoundWins
+	// the cells brought the scores back, not when they were reached
+	g.scoreReached = map[Camp][]uint32{}
+	for c, ticks := range cp.ScoreReached {
+		g.scoreReached[c] = ticks
+	}
+	g.toRewardName = cp.ToRewardName
+	g.stats = map[uint64]*PlayerStats{}
+	for _, s := range cp.Stats {
+		if s.StolenFrom == nil {
+			s.StolenFrom = map[Camp]int{}
+		}
+		g.stats[s.PlayerID] = s
+	}
+	g.GameStatus = GameRunning
+	if cp.Paused {
+		g.GameStatus = GamePaused
+		g.pausedAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 20, GameDuration: 60}
+	g := newTestGame(cfg)
+	g.seedRound(11)
+	g.GameStatus = GameRunning
+	g.roundTicks = g.secondsToTicks(cfg.GameDuration)
+	g.AddPlayer(1, BTC)
+	g.AddPlayer(2, ETH)
+	g.AddPlayer(3, BNB)
+	// the replay is saved in a part per checkpoint
+	var parts []model.CheckpointReplay
+	for i := 0; i < 300; i++ {
+		g.Update()
+		if i%100 == 0 {
+			if r := g.replayPart(); r != nil {
+				parts = append(parts, model.CheckpointReplay{Seq: uint32(len(parts)), Data: r.Serialize()})
+			}
+		}
+	}
+	if r := g.replayPart(); r != nil {
+		parts = append(parts, model.CheckpointReplay{Seq: uint32(len(parts)), Data: r.Serialize()})
+	}
+	if len(parts) < 2 || g.replayPart() != nil {
+		t.Fatalf("%d replay parts", len(parts))
+	}
+
+	data, err := json.Marshal(g.captureCheckpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestGame(&config.Config{FPS: 30, ItemFrameChance: 20, GameDuration: 60})
+	restored.Reset()
+	if err := restored.applyCheckpoint(&cp, parts); err != nil {
+		t.Fatal(err)
+	}
+	if restored.GameStatus != GameRunning || restored.tick != g.tick || restored.remaining() != g.remaining() {
+		t.Fatalf("restored at tick %d status %v", restored.tick, restored.GameStatus)
+	}
+
+	// the same rng state and the same balls play the rest of the round the same way
+	for i := 0; i < 300; i++ {
+		g.Update()
+		restored.Update()
+	}
+	if !equalCells(restored.Map.Cells, g.Map.Cells) {
+		t.Fatal("restored round diverged")
+	}
+	if g.liveItems() != restored.liveItems() || g.nextItemID != restored.nextItemID {
+		t.Fatalf("items %d/%d, restored %d/%d", g.liveItems(), g.nextItemID, restored.liveItems(), restored.nextItemID)
+	}
+	if !bytes.Equal(g.replay.Serialize(), restored.replay.Serialize()) {
+		t.Fatal("restored replay differs")
+	}
+
+	other := newTestGame(&config.Config{FPS: 30, Map: config.MapConfig{Row: 10, Column: 10}})
+	other.Reset()
+	if err := other.applyCheckpoint(&cp, parts); err == nil {
+		t.Fatal("checkpoint of another map applied")
+	}
+}
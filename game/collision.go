@@ -0,0 +1,131 @@
+package game
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	BallCollisionsOff   = "off"
+	BallCollisionsEnemy = "enemy" // balls of different camps bounce on each other
+	BallCollisionsAll   = "all"   // every ball bounces, the same camp included
+
+	collisionsRoute  = "onBallCollision"
+	maxCollisionLogs = 256 // collisions kept for the next frame, the broadcaster may fall behind
+)
+
+// BallCollision is sent to the clients when two balls bounce on each other
+type BallCollision struct {
+	Tick uint32  `json:"tick"`
+	A    uint64  `json:"a"`
+	B    uint64  `json:"b"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+type BallCollisions struct {
+	Collisions []BallCollision `json:"collisions"`
+}
+
+func validBallCollisions(mode string) error {
+	switch mode {
+	case "", BallCollisionsOff, BallCollisionsEnemy, BallCollisionsAll:
+		return nil
+	}
+	return fmt.Errorf("unknown ball_collisions %q", mode)
+}
+
+// collideBalls bounces the overlapping balls once they all moved, pairs are taken in id order
+// so that the outcome is the same in every run
+func (g *Game) collideBalls() {
+	mode := g.cfg.BallCollisions
+	if mode != BallCollisionsEnemy && mode != BallCollisionsAll {
+		return
+	}
+	var balls []*Player
+	g.rangePlayers(func(p *Player) bool {
+		if p.playerObj != nil {
+			balls = append(balls, p)
+		}
+		return true
+	})
+	for i, a := range balls {
+		for _, b := range balls[i+1:] {
+			if mode == BallCollisionsEnemy && a.Camp == b.Camp {
+				continue
+			}
+			if g.bounce(a, b) {
+				g.countBounce(a)
+				g.countBounce(b)
+				x, y := a.GetCenter()
+				bx, by := b.GetCenter()
+				x, y = space2MapXY((x+bx)/2, (y+by)/2)
+				if len(g.collisions) < maxCollisionLogs {
+					g.collisions = append(g.collisions, BallCollision{Tick: g.tick, A: a.ID, B: b.ID, X: x, Y: y})
+				}
+			}
+		}
+	}
+}
+
+// bounce resolves an elastic collision between two balls weighted by their area, false when they don't touch.
+// updateEffects sets the speed of every ball from its effects at the next tick, so the collision only
+// decides the new directions.
+func (g *Game) bounce(a, b *Player) bool {
+	ax, ay := a.GetCenter()
+	bx, by := b.GetCenter()
+	dx, dy := bx-ax, by-ay
+	d := math.Hypot(dx, dy)
+	minD := float64(a.R + b.R)
+	if d >= minD || d == 0 {
+		return false
+	}
+	nx, ny := dx/d, dy/d
+
+	// push them apart so they don't stick together
+	push := (minD - d) / 2
+	nudge(a, -nx*push, -ny*push)
+	nudge(b, nx*push, ny*push)
+
+	va := a.Vx*nx + a.Vy*ny
+	vb := b.Vx*nx + b.Vy*ny
+	if va-vb <= 0 {
+		// already moving away from each other
+		return true
+	}
+	avx, avy, bvx, bvy := a.Vx, a.Vy, b.Vx, b.Vy
+	ma, mb := float64(a.R*a.R), float64(b.R*b.R)
+	na := (va*(ma-mb) + 2*mb*vb) / (ma + mb)
+	nb := (vb*(mb-ma) + 2*ma*va) / (ma + mb)
+	a.Vx += (na - va) * nx
+	a.Vy += (na - va) * ny
+	b.Vx += (nb - vb) * nx
+	b.Vy += (nb - vb) * ny
+	// a ball left still would never move again, it bounces off the other one at its speed instead
+	if a.Vx == 0 && a.Vy == 0 {
+		a.Vx, a.Vy = avx-2*va*nx, avy-2*va*ny
+	}
+	if b.Vx == 0 && b.Vy == 0 {
+		b.Vx, b.Vy = bvx-2*vb*nx, bvy-2*vb*ny
+	}
+	return true
+}
+
+// nudge moves the ball, it stops against the cells, walls and edges it bounces on
+func nudge(p *Player, dx, dy float64) {
+	if c := p.playerObj.Check(dx, dy, getCollisionTags(p.Camp)...); c != nil {
+		for _, obj := range c.Objects {
+			dx, dy = resolvDxDy(dx, dy, c.ContactWithObject(obj))
+		}
+	}
+	p.playerObj.X += dx
+	p.playerObj.Y += dy
+	p.playerObj.Update()
+}
+
+// takeCollisions returns the collisions since the last call
+func (g *Game) takeCollisions() []BallCollision {
+	c := g.collisions
+	g.collisions = nil
+	return c
+}
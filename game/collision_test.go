@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func placeBall(p *Player, x, y, vx, vy float64) {
+	p.playerObj.X, p.playerObj.Y = x, y
+	p.playerObj.Update()
+	p.Vx, p.Vy = vx, vy
+}
+
+func TestBallCollisions(t *testing.T) {
+	for _, c := range []struct {
+		mode     string
+		sameCamp bool
+		bounce   bool
+	}{
+		{BallCollisionsOff, false, false},
+		{BallCollisionsEnemy, false, true},
+		{BallCollisionsEnemy, true, false},
+		{BallCollisionsAll, true, true},
+	} {
+		g := newTestGame(&config.Config{FPS: 30, BallCollisions: c.mode})
+		a := g.AddPlayer(1, BTC)
+		camp := ETH
+		if c.sameCamp {
+			camp = BTC
+		}
+		b := g.AddPlayer(2, camp)
+		placeBall(a, 200, 200, 1, 0)
+		placeBall(b, 208, 200, -1, 0)
+
+		g.collideBalls()
+		collisions := g.takeCollisions()
+		if !c.bounce {
+			if len(collisions) != 0 || a.Vx != 1 || b.Vx != -1 {
+				t.Fatalf("%s: balls of the same camp %v bounced", c.mode, c.sameCamp)
+			}
+			continue
+		}
+		if len(collisions) != 1 || collisions[0].A != 1 || collisions[0].B != 2 {
+			t.Fatalf("%s: collisions %+v", c.mode, collisions)
+		}
+		// same mass, head-on: the velocities are swapped
+		if math.Abs(a.Vx+1) > 1e-9 || math.Abs(b.Vx-1) > 1e-9 {
+			t.Fatalf("%s: velocities after the bounce %v %v", c.mode, a.Vx, b.Vx)
+		}
+		ax, _ := a.GetCenter()
+		bx, _ := b.GetCenter()
+		if bx-ax < float64(a.R+b.R)-1e-9 {
+			t.Fatalf("%s: balls still overlap", c.mode)
+		}
+	}
+}
+
+func TestBallCollisionEdges(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, BallCollisions: BallCollisionsAll})
+	a := g.AddPlayer(1, BTC)
+	b := g.AddPlayer(2, BTC)
+	// a is against the left edge, the push must not move it out of the space
+	placeBall(a, edgeWidth, 200, 0, 0)
+	placeBall(b, edgeWidth+6, 200, -1, 0)
+	g.collideBalls()
+	if a.playerObj.X < edgeWidth {
+		t.Fatalf("ball pushed into the edge at x %v", a.playerObj.X)
+	}
+
+	// b hits a still ball of the same mass head-on, it doesn't stop
+	placeBall(a, 200, 200, 1, 0)
+	placeBall(b, 208, 200, -1, 0)
+	a.Vx = 0
+	g.collideBalls()
+	if b.Vx == 0 && b.Vy == 0 {
+		t.Fatal("ball stopped by the collision")
+	}
+	if math.Abs(b.Vx-1) > 1e-9 || math.Abs(a.Vx+1) > 1e-9 {
+		t.Fatalf("velocities after the bounce %v %v", a.Vx, b.Vx)
+	}
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+const (
+	effectsRoute = "onPlayerEffects"
+
+	accelerateSeconds = 5
+	growSeconds       = 10
+	freezeSeconds     = 3
+)
+
+// Effect is an item effect active on a ball
+type Effect struct {
+	Type  ItemType
+	Until uint32 // tick the effect expires at, 0 lasts until it's used up
+}
+
+func (p *Player) hasEffect(t ItemType) bool {
+	for _, e := range p.effects {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// addEffect starts the effect or extends it, the same effect never stacks
+func (p *Player) addEffect(t ItemType, until uint32) {
+	for i, e := range p.effects {
+		if e.Type == t {
+			if e.Until != 0 && (until == 0 || until > e.Until) {
+				p.effects[i].Until = until
+			}
+			return
+		}
+	}
+	p.effects = append(p.effects, Effect{Type: t, Until: until})
+}
+
+func (p *Player) removeEffect(t ItemType) {
+	for i, e := range p.effects {
+		if e.Type == t {
+			p.effects = append(p.effects[:i], p.effects[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *Game) secondsToTicks(seconds int) uint32 {
+	return uint32(seconds * g.tickRate())
+}
+
+// updateEffects drops the expired effects of the ball and sets its speed from the ones left
+func (g *Game) updateEffects(p *Player) {
+	effects := p.effects[:0]
+	for _, e := range p.effects {
+		if e.Until != 0 && e.Until <= g.tick {
+			if e.Type == ItemGrow {
+				p.resize(defaultPlayerPixelR)
+			}
+			continue
+		}
+		effects = append(effects, e)
+	}
+	p.effects = effects
+
+	speed := float64(playerInitialVelocity)
+	if p.hasEffect(ItemAccelerator) {
+		speed *= acceleratorFactor
+	}
+	if max := g.maxVelocity(); speed > max {
+		speed = max
+	}
+	speed = g.perTick(speed)
+	if v := math.Hypot(p.Vx, p.Vy); v > 0 {
+		p.Vx, p.Vy = p.Vx/v*speed, p.Vy/v*speed
+	}
+}
+
+// maxVelocity is the speed cap in pixels per second, by default half a cell per tick so that balls never go through one
+func (g *Game) maxVelocity() float64 {
+	if g.cfg.MaxVelocity > 0 {
+		return g.cfg.MaxVelocity
+	}
+	return float64(minInt(g.layout.CellWidth, g.layout.CellHeight)) / 2 * float64(g.tickRate())
+}
+
+// perTick is the distance covered in a tick at speed pixels per second, the balls move by that much every tick
+// so that the tick rate doesn't change the game
+func (g *Game) perTick(speed float64) float64 {
+	return speed / float64(g.tickRate())
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// resize changes the radius of the ball, it keeps its center
+func (p *Player) resize(r int) {
+	d := float64(r - p.R)
+	p.R = r
+	p.playerObj.X -= d
+	p.playerObj.Y -= d
+	p.playerObj.W = float64(2 * r)
+	p.playerObj.H = float64(2 * r)
+	p.playerObj.Update()
+}
+
+// serializeEffects encodes the effects of every ball having some, sorted by ball id
+func (g *Game) serializeEffects() []byte {
+	var players []*Player
+	g.Players.Range(func(key, value interface{}) bool {
+		if v, ok := value.(*Player); ok && v != nil && len(v.effects) > 0 {
+			players = append(players, v)
+		}
+		return true
+	})
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+
+	bytesBuffer := bytes.NewBuffer(make([]byte, 0))
+	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(players)))
+	for _, p := range players {
+		binary.Write(bytesBuffer, binary.BigEndian, p.ID)
+		binary.Write(bytesBuffer, binary.BigEndian, uint8(len(p.effects)))
+		for _, e := range p.effects {
+			binary.Write(bytesBuffer, binary.BigEndian, uint8(e.Type))
+			binary.Write(bytesBuffer, binary.BigEndian, e.Until)
+		}
+	}
+	return bytesBuffer.Bytes()
+}
+
+/*
+frame number: 4 bytes
+tick: 4 bytes
+tick rate: 2 bytes
+player number: 4 bytes
+players: id 8 bytes, effect number 1 byte, effects 5 * effect number bytes (type 1 byte, until tick 4 bytes, 0 until used up)
+*/
+func encodeEffects(frameNumber, tick uint32, tickRate int, effects []byte) []byte {
+	b := make([]byte, 10, 10+len(effects))
+	binary.BigEndian.PutUint32(b, frameNumber)
+	binary.BigEndian.PutUint32(b[4:], tick)
+	binary.BigEndian.PutUint16(b[8:], uint16(tickRate))
+	return append(b, effects...)
+}
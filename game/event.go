@@ -0,0 +1,164 @@
+package game
+
+import "sync"
+
+type EventType string
+
+const (
+	EventCellCaptured  EventType = "cell_captured"
+	EventItemSpawned   EventType = "item_spawned"
+	EventItemCollected EventType = "item_collected"
+	EventItemExpired   EventType = "item_expired"
+	EventEdgeBounce    EventType = "edge_bounce"
+	EventLeadChanged   EventType = "lead_changed"
+
+	eventsRoute     = "onGameEvents"
+	maxEventBatches = 256 // ticks kept for the next frame, the broadcaster may fall behind
+)
+
+// Event is something that happened during a tick, the ids that don't apply to its type are left out
+type Event struct {
+	Type     EventType  `json:"type"`
+	Ball     uint64     `json:"ball,omitempty"`
+	PlayerID uint64     `json:"player_id,omitempty"` // player of the ball, the owner of a split ball
+	Camp     Camp       `json:"camp,omitempty"`      // camp of the ball, the new leader
+	From     Camp       `json:"from,omitempty"`      // camp that held the cell, the previous leader
+	Cell     *CellIndex `json:"cell,omitempty"`
+	ItemID   uint32     `json:"item_id,omitempty"`
+	ItemType ItemType   `json:"item_type,omitempty"` // item types start at 1
+	X        float64    `json:"x,omitempty"`         // map position of the item or the ball, never 0 inside the edges
+	Y        float64    `json:"y,omitempty"`
+}
+
+type CellIndex struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// TickEvents is the batch of events of a tick, in the order they happened
+type TickEvents struct {
+	Tick   uint32  `json:"tick"`
+	Events []Event `json:"events"`
+}
+
+// GameEvents is sent on onGameEvents with every frame following ticks that had events
+type GameEvents struct {
+	Ticks []TickEvents `json:"ticks"`
+}
+
+type subscriber struct {
+	id uint64
+	f  func(TickEvents)
+}
+
+type subscribers struct {
+	mu     sync.Mutex
+	nextID uint64
+	list   []subscriber
+}
+
+// Subscribe calls f on the tick goroutine with the batch of every tick that had events, f must
+// neither block nor change the batch. It returns a func removing f.
+func (g *Game) Subscribe(f func(TickEvents)) func() {
+	s := &g.subscribers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := s.nextID
+	s.list = append(s.list, subscriber{id: id, f: f})
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.list {
+			if sub.id == id {
+				s.list = append(s.list[:i:i], s.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (s *subscribers) notify(batch TickEvents) {
+	s.mu.Lock()
+	list := s.list
+	s.mu.Unlock()
+	for _, sub := range list {
+		sub.f(batch)
+	}
+}
+
+func (g *Game) emit(e Event) {
+	g.events = append(g.events, e)
+}
+
+func ballEvent(t EventType, p *Player) Event {
+	e := Event{Type: t, Ball: p.ID, PlayerID: p.ID, Camp: p.Camp}
+	if p.owner != 0 {
+		e.PlayerID = p.owner
+	}
+	return e
+}
+
+// emitItem is called before the item leaves the game, p is nil unless a ball picked it up
+func (g *Game) emitItem(t EventType, id uint32, p *Player) {
+	v, ok := g.Items.Load(id)
+	if !ok {
+		return
+	}
+	item := v.(*ItemObject)
+	e := Event{Type: t}
+	if p != nil {
+		e = ballEvent(t, p)
+	}
+	e.ItemID, e.ItemType = item.Id, item.Item.Type
+	e.X, e.Y = space2MapXY(item.Center())
+	g.emit(e)
+}
+
+func (g *Game) emitEdgeBounce(p *Player) {
+	e := ballEvent(EventEdgeBounce, p)
+	e.X, e.Y = space2MapXY(p.GetCenter())
+	g.emit(e)
+}
+
+func (g *Game) emitCapture(p *Player, x, y int, prev Camp) {
+	e := ballEvent(EventCellCaptured, p)
+	e.From = prev
+	e.Cell = &CellIndex{X: x, Y: y}
+	g.emit(e)
+	g.captured = true
+}
+
+// checkLead tells when a single camp takes the lead, ties don't change it
+func (g *Game) checkLead() {
+	tied, _ := leaders(g.campScores())
+	if len(tied) != 1 || tied[0] == g.leader {
+		return
+	}
+	g.emit(Event{Type: EventLeadChanged, Camp: tied[0], From: g.leader})
+	g.leader = tied[0]
+}
+
+// flushEvents closes the batch of the tick, it goes to the subscribers and waits for the next frame
+func (g *Game) flushEvents() {
+	if g.captured {
+		g.captured = false
+		g.checkLead()
+	}
+	if len(g.events) == 0 {
+		return
+	}
+	batch := TickEvents{Tick: g.tick, Events: g.events}
+	g.events = nil
+	if len(g.tickEvents) < maxEventBatches {
+		g.tickEvents = append(g.tickEvents, batch)
+	}
+	g.subscribers.notify(batch)
+}
+
+// takeEvents returns the batches since the last call
+func (g *Game) takeEvents() []TickEvents {
+	e := g.tickEvents
+	g.tickEvents = nil
+	return e
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func playEvents(seed int64) ([]TickEvents, []TickEvents) {
+	g := newTestGame(&config.Config{FPS: 30, GameDuration: 20, ItemFrameChance: 2, ItemTTL: 1})
+	g.seedRound(seed)
+	g.GameStatus = GameRunning
+	g.roundTicks = 600
+	var got []TickEvents
+	unsubscribe := g.Subscribe(func(batch TickEvents) { got = append(got, batch) })
+	btc := g.AddPlayer(1, BTC)
+	g.AddPlayer(2, ETH)
+	// heading for the left edge
+	placeBall(btc, edgeWidth+2, btc.playerObj.Y, -3, 0.5)
+	var framed []TickEvents
+	for i := 0; i < 300; i++ {
+		g.advance()
+		if i%10 == 0 {
+			framed = append(framed, g.NextFrame().Events...)
+		}
+	}
+	framed = append(framed, g.NextFrame().Events...)
+	unsubscribe()
+	g.advance()
+	return got, framed
+}
+
+func TestEvents(t *testing.T) {
+	got, framed := playEvents(7)
+	if len(got) == 0 || !reflect.DeepEqual(got, framed) {
+		t.Fatalf("subscriber got %d batches, frames %d", len(got), len(framed))
+	}
+	counts := map[EventType]int{}
+	items := map[uint32]EventType{}
+	for i, batch := range got {
+		if i > 0 && batch.Tick <= got[i-1].Tick {
+			t.Fatalf("batch of tick %d after tick %d", batch.Tick, got[i-1].Tick)
+		}
+		for _, e := range batch.Events {
+			counts[e.Type]++
+			switch e.Type {
+			case EventCellCaptured:
+				if e.Cell == nil || e.From == e.Camp || (e.PlayerID != 1 && e.PlayerID != 2) {
+					t.Fatalf("capture %+v", e)
+				}
+			case EventItemSpawned:
+				items[e.ItemID] = e.Type
+			case EventItemCollected, EventItemExpired:
+				if items[e.ItemID] != EventItemSpawned {
+					t.Fatalf("item %d %s without spawning", e.ItemID, e.Type)
+				}
+				items[e.ItemID] = e.Type
+			}
+		}
+	}
+	for _, typ := range []EventType{EventCellCaptured, EventItemSpawned, EventItemExpired, EventEdgeBounce, EventLeadChanged} {
+		if counts[typ] == 0 {
+			t.Errorf("no %s event in %v", typ, counts)
+		}
+	}
+
+	again, _ := playEvents(7)
+	if !reflect.DeepEqual(got, again) {
+		t.Fatal("the same round gave different events")
+	}
+}
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+)
+
+type FrameType uint8
+
+const (
+	KeyFrame FrameType = iota
+	DeltaFrame
+
+	keyFrameRoute   = "onUpdate"
+	deltaFrameRoute = "onDelta"
+)
+
+type Frame struct {
+	Type       FrameType
+	Data       []byte
+	Effects    []byte          // effects of the balls, only set when they changed or with a keyframe
+	Collisions []BallCollision // ball collisions since the previous frame
+	Events     []TickEvents    // gameplay events since the previous frame
+}
+
+func (f Frame) Route() string {
+	if f.Type == DeltaFrame {
+		return deltaFrameRoute
+	}
+	return keyFrameRoute
+}
+
+// frameState is what the clients know about the game after receiving a frame,
+// deltas are computed against the previous one.
+type frameState struct {
+	cells   []Camp
+	players map[uint64][]byte
+	items   map[uint32][]byte
+	effects []byte
+	tick    uint32
+}
+
+type frameEncoder struct {
+	interval   uint32 // send a keyframe every interval frames, 0 means keyframes only
+	tickRate   int
+	sinceKey   uint32
+	forceKey   int32
+	baseNumber uint32
+	last       *frameState
+}
+
+func newFrameEncoder(interval, tickRate int) *frameEncoder {
+	if interval < 0 {
+		interval = 0
+	}
+	return &frameEncoder{interval: uint32(interval), tickRate: tickRate}
+}
+
+// requestKeyFrame makes the next frame a keyframe, safe to call from any goroutine
+func (e *frameEncoder) requestKeyFrame() {
+	atomic.StoreInt32(&e.forceKey, 1)
+}
+
+func (e *frameEncoder) needKeyFrame(s *frameState) bool {
+	if atomic.SwapInt32(&e.forceKey, 0) == 1 {
+		return true
+	}
+	if e.interval == 0 || e.last == nil || len(e.last.cells) != len(s.cells) {
+		return true
+	}
+	return e.sinceKey+1 >= e.interval
+}
+
+func (e *frameEncoder) encode(frameNumber uint32, s *frameState) Frame {
+	var f Frame
+	if e.needKeyFrame(s) {
+		e.sinceKey = 0
+		f = Frame{Type: KeyFrame, Data: encodeKeyFrame(frameNumber, s)}
+	} else {
+		e.sinceKey++
+		f = Frame{Type: DeltaFrame, Data: encodeDeltaFrame(frameNumber, e.baseNumber, e.last, s)}
+	}
+	if f.Type == KeyFrame || !bytes.Equal(e.last.effects, s.effects) {
+		f.Effects = encodeEffects(frameNumber, s.tick, e.tickRate, s.effects)
+	}
+	e.baseNumber = frameNumber
+	e.last = s
+	return f
+}
+
+func (g *Game) captureFrameState() *frameState {
+	s := &frameState{
+		cells:   make([]Camp, len(g.Map.Cells)),
+		players: map[uint64][]byte{},
+		items:   map[uint32][]byte{},
+		effects: g.serializeEffects(),
+		tick:    g.tick,
+	}
+	copy(s.cells, g.Map.Cells)
+	g.Players.Range(func(key, value interface{}) bool {
+		if v, ok := value.(*Player); ok && v != nil {
+			s.players[v.ID] = v.Serialize()
+		}
+		return true
+	})
+	g.Items.Range(func(key, value interface{}) bool {
+		if v, ok := value.(*ItemObject); ok && v != nil {
+			s.items[v.Id] = v.Serialize()
+		}
+		return true
+	})
+	return s
+}
+
+// NextFrame returns the next frame to broadcast, a keyframe or a delta against the previous frame
+func (g *Game) NextFrame() Frame {
+	frameNumber := atomic.AddUint32(&g.frameNumber, 1)
+	f := g.frames.encode(frameNumber, g.captureFrameState())
+	f.Collisions = g.takeCollisions()
+	f.Events = g.takeEvents()
+	return f
+}
+
+/*
+frame number: 4 bytes
+map size: 4 bytes
+map: map size bytes
+player number: 4 bytes
+players: 49 + 5 * effect number bytes each, see Player.Serialize
+item number: 4 bytes
+items: 21 * items number bytes
+*/
+func encodeKeyFrame(frameNumber uint32, s *frameState) []byte {
+	bytesBuf := bytes.NewBuffer([]byte{})
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, frameNumber)
+	bytesBuf.Write(b)
+
+	m := Map{Cells: s.cells}
+	binary.BigEndian.PutUint32(b, m.Size())
+	bytesBuf.Write(b)
+	bytesBuf.Write(m.Serialize())
+
+	binary.BigEndian.PutUint32(b, uint32(len(s.players)))
+	bytesBuf.Write(b)
+	for _, p := range s.players {
+		bytesBuf.Write(p)
+	}
+
+	binary.BigEndian.PutUint32(b, uint32(len(s.items)))
+	bytesBuf.Write(b)
+	for _, i := range s.items {
+		bytesBuf.Write(i)
+	}
+	return bytesBuf.Bytes()
+}
+
+/*
+frame number: 4 bytes
+base frame number: 4 bytes
+changed cell number: 4 bytes
+changed cells: 3 * changed cell number bytes (index 2 bytes, camp 1 byte)
+player number: 4 bytes
+players: new or changed players, see Player.Serialize
+removed player number: 4 bytes
+removed players: 8 * removed player number bytes
+item number: 4 bytes
+items: 21 * item number bytes, new items
+removed item number: 4 bytes
+removed items: 4 * removed item number bytes
+*/
+func encodeDeltaFrame(frameNumber, baseNumber uint32, prev, s *frameState) []byte {
+	bytesBuf := bytes.NewBuffer([]byte{})
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, frameNumber)
+	bytesBuf.Write(b)
+	binary.BigEndian.PutUint32(b, baseNumber)
+	bytesBuf.Write(b)
+
+	var cellBytes []byte
+	cellNumber := uint32(0)
+	for i, c := range s.cells {
+		if prev.cells[i] != c {
+			cellNumber++
+			cellBytes = append(cellBytes, byte(i>>8), byte(i), byte(c))
+		}
+	}
+	binary.BigEndian.PutUint32(b, cellNumber)
+	bytesBuf.Write(b)
+	bytesBuf.Write(cellBytes)
+
+	var playerBytes []byte
+	playerNumber := uint32(0)
+	for id, p := range s.players {
+		if old, ok := prev.players[id]; !ok || !bytes.Equal(old, p) {
+			playerNumber++
+			playerBytes = append(playerBytes, p...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, playerNumber)
+	bytesBuf.Write(b)
+	bytesBuf.Write(playerBytes)
+
+	var removedPlayers []byte
+	removedPlayerNumber := uint32(0)
+	for id := range prev.players {
+		if _, ok := s.players[id]; !ok {
+			removedPlayerNumber++
+			id8 := make([]byte, 8)
+			binary.BigEndian.PutUint64(id8, id)
+			removedPlayers = append(removedPlayers, id8...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, removedPlayerNumber)
+	bytesBuf.Write(b)
+	bytesBuf.Write(removedPlayers)
+
+	var itemBytes []byte
+	itemNumber := uint32(0)
+	for id, i := range s.items {
+		if old, ok := prev.items[id]; !ok || !bytes.Equal(old, i) {
+			itemNumber++
+			itemBytes = append(itemBytes, i...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, itemNumber)
+	bytesBuf.Write(b)
+	bytesBuf.Write(itemBytes)
+
+	var removedItems []byte
+	removedItemNumber := uint32(0)
+	for id := range prev.items {
+		if _, ok := s.items[id]; !ok {
+			removedItemNumber++
+			id4 := make([]byte, 4)
+			binary.BigEndian.PutUint32(id4, id)
+			removedItems = append(removedItems, id4...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, removedItemNumber)
+	bytesBuf.Write(b)
+	bytesBuf.Write(removedItems)
+	return bytesBuf.Bytes()
+}
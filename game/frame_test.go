@@ -0,0 +1,231 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+const itemBytes = 21
+
+// playerRecord is a player decoded like a client does
+type playerRecord struct {
+	Version   uint8
+	ID        uint64
+	Camp      Camp
+	R         uint16
+	X, Y      float64
+	Vx, Vy    float64
+	SpawnTick uint32
+	Effects   []Effect
+}
+
+func decodePlayer(r *bytes.Reader) (playerRecord, []byte) {
+	start := r.Len()
+	var p playerRecord
+	var camp, effects, effectType uint8
+	binary.Read(r, binary.BigEndian, &p.Version)
+	binary.Read(r, binary.BigEndian, &p.ID)
+	binary.Read(r, binary.BigEndian, &camp)
+	binary.Read(r, binary.BigEndian, &p.R)
+	binary.Read(r, binary.BigEndian, &p.X)
+	binary.Read(r, binary.BigEndian, &p.Y)
+	binary.Read(r, binary.BigEndian, &p.Vx)
+	binary.Read(r, binary.BigEndian, &p.Vy)
+	binary.Read(r, binary.BigEndian, &p.SpawnTick)
+	binary.Read(r, binary.BigEndian, &effects)
+	p.Camp = Camp(camp)
+	for i := uint8(0); i < effects; i++ {
+		var e Effect
+		binary.Read(r, binary.BigEndian, &effectType)
+		binary.Read(r, binary.BigEndian, &e.Until)
+		e.Type = ItemType(effectType)
+		p.Effects = append(p.Effects, e)
+	}
+	// the raw record, to compare it with the server one
+	raw := make([]byte, start-r.Len())
+	r.Seek(int64(-len(raw)), io.SeekCurrent)
+	r.Read(raw)
+	return p, raw
+}
+
+// decodedFrame mirrors what a client rebuilds from keyframes and deltas
+type decodedFrame struct {
+	number  uint32
+	cells   []Camp
+	players map[uint64][]byte
+	items   map[uint32][]byte
+}
+
+func decodeKeyFrame(t *testing.T, b []byte) *decodedFrame {
+	r := bytes.NewReader(b)
+	f := &decodedFrame{players: map[uint64][]byte{}, items: map[uint32][]byte{}}
+	var mapSize, n uint32
+	binary.Read(r, binary.BigEndian, &f.number)
+	binary.Read(r, binary.BigEndian, &mapSize)
+	packed := make([]byte, mapSize)
+	r.Read(packed)
+	for _, c := range packed {
+		f.cells = append(f.cells, Camp(c>>4), Camp(c&campMaskRight))
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		p, raw := decodePlayer(r)
+		f.players[p.ID] = raw
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		item := make([]byte, itemBytes)
+		r.Read(item)
+		f.items[binary.BigEndian.Uint32(item)] = item
+	}
+	if r.Len() != 0 {
+		t.Fatalf("keyframe has %d trailing bytes", r.Len())
+	}
+	return f
+}
+
+func (f *decodedFrame) applyDelta(t *testing.T, b []byte) {
+	r := bytes.NewReader(b)
+	var number, base, n uint32
+	binary.Read(r, binary.BigEndian, &number)
+	binary.Read(r, binary.BigEndian, &base)
+	if base != f.number {
+		t.Fatalf("delta %d is based on frame %d, have frame %d", number, base, f.number)
+	}
+	f.number = number
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		c := make([]byte, 3)
+		r.Read(c)
+		f.cells[int(c[0])<<8|int(c[1])] = Camp(c[2])
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		p, raw := decodePlayer(r)
+		f.players[p.ID] = raw
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		var id uint64
+		binary.Read(r, binary.BigEndian, &id)
+		delete(f.players, id)
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		item := make([]byte, itemBytes)
+		r.Read(item)
+		f.items[binary.BigEndian.Uint32(item)] = item
+	}
+	binary.Read(r, binary.BigEndian, &n)
+	for i := uint32(0); i < n; i++ {
+		var id uint32
+		binary.Read(r, binary.BigEndian, &id)
+		delete(f.items, id)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("delta has %d trailing bytes", r.Len())
+	}
+}
+
+func TestDeltaFrames(t *testing.T) {
+	g := newTestGame(&config.Config{KeyframeInterval: 10, ItemFrameChance: 2})
+	g.GameStatus = GameRunning
+	g.AddPlayer(1, BTC)
+	g.AddPlayer(2, ETH)
+
+	var client *decodedFrame
+	keyFrames := 0
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			g.AddPlayer(3, BNB)
+		}
+		if i == 70 {
+			g.Players.Delete(uint64(2))
+		}
+		g.Update()
+		f := g.NextFrame()
+		if f.Type == KeyFrame {
+			keyFrames++
+			client = decodeKeyFrame(t, f.Data)
+		} else {
+			if client == nil {
+				t.Fatal("delta before any keyframe")
+			}
+			client.applyDelta(t, f.Data)
+		}
+
+		want := g.captureFrameState()
+		if !equalCells(client.cells, want.cells) {
+			t.Fatalf("frame %d: cells differ", client.number)
+		}
+		if len(client.players) != len(want.players) || len(client.items) != len(want.items) {
+			t.Fatalf("frame %d: have %d players %d items, want %d players %d items",
+				client.number, len(client.players), len(client.items), len(want.players), len(want.items))
+		}
+		for id, p := range want.players {
+			if !bytes.Equal(client.players[id], p) {
+				t.Fatalf("frame %d: player %d differs", client.number, id)
+			}
+		}
+	}
+	if keyFrames != 10 {
+		t.Fatalf("got %d keyframes, want 10", keyFrames)
+	}
+
+	g.frames.requestKeyFrame()
+	if f := g.NextFrame(); f.Type != KeyFrame {
+		t.Fatal("requested keyframe was not sent")
+	}
+}
+
+func equalCells(a, b []Camp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPlayerRecord(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, ItemFrameChance: 2})
+	g.GameStatus = GameRunning
+	g.AddPlayer(1, BTC)
+	for i := 0; i < 20; i++ {
+		g.Update()
+	}
+	p := g.AddPlayer(2, ETH)
+	p.Vx, p.Vy = 1.5, -0.25
+	p.addEffect(ItemAccelerator, 90)
+	p.addEffect(ItemShield, 0)
+
+	b := p.Serialize()
+	if len(b) != int(p.Size()) {
+		t.Fatalf("record of %d bytes, Size says %d", len(b), p.Size())
+	}
+	got, _ := decodePlayer(bytes.NewReader(b))
+	x, y := space2MapXY(p.GetCenter())
+	want := playerRecord{Version: playerRecordVersion, ID: 2, Camp: ETH, R: uint16(p.R), X: x, Y: y, Vx: 1.5, Vy: -0.25, SpawnTick: 20, Effects: p.effects}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded %+v, want %+v", got, want)
+	}
+
+	// a keyframe is as long as Size says, items included
+	for i := 0; i < 100 && g.liveItems() == 0; i++ {
+		g.Update()
+	}
+	if g.liveItems() == 0 {
+		t.Fatal("no item spawned")
+	}
+	if key, _ := g.Serialize(); len(key) != int(g.Size()) {
+		t.Fatalf("keyframe of %d bytes, Size says %d", len(key), g.Size())
+	}
+}
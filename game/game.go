@@ -1,12 +1,12 @@
 package game
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
 	"gorm.io/gorm"
+	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +27,7 @@ const (
 	GameNotStarted GameStatus = iota
 	GameRunning
 	GameStopped
+	GamePaused
 )
 const (
 	CellTag           = "CELL"
@@ -37,7 +38,10 @@ const (
 	minCellSize = 5
 	edgeWidth   = minCellSize + lineWidth
 
-	playerInitialVelocity = 1
+	playerInitialVelocity = 30 // pixels per second
+	defaultTickRate       = 30
+
+	maxCatchUpTicks = 5
 )
 
 type Game struct {
@@ -45,16 +49,59 @@ type Game struct {
 	cfg               *config.Config
 	sdkClient         *zecreyface.Client
 	onGameStart       func(context.Context)
-	onGameStop        func(context.Context)
+	onGameStop        func(context.Context, GameStop)
 	onCampVotesChange func(camp Camp, votes int32)
 
 	res *res
 
-	space       *resolv.Space
-	frameNumber uint32
-	campVotes   sync.Map
-
+	space        *resolv.Space
+	cells        []*resolv.Object // one per cell, nil on walls and holes
+	nextBallID   uint64
+	nextItemID   uint32
+	collisions   []BallCollision // since the last frame
+	events       []Event         // of the current tick
+	tickEvents   []TickEvents    // since the last frame
+	subscribers  subscribers
+	captured     bool // a cell changed camp during the tick
+	leader       Camp
+	subRoundWins []Camp
+	scores       map[Camp]int      // of the cells each camp holds, kept up by captureCell
+	scoreReached map[Camp][]uint32 // first tick each camp reached each score, index score-1
+	stats        map[uint64]*PlayerStats
+	pickups      map[ItemType]int // items picked up per type
+
+	phase          Phase
+	phaseTicks     uint32
+	phaseStartedAt time.Time
+	onPhaseChange  func(PhaseChange)
+	layout         *MapLayout
+	seed           int64
+	rng            *rand.Rand // every random decision of a round comes from here
+	rngSource      *countingSource
+	replay         *Replay
+	tick           uint32 // number of updates since the round was reset
+	roundTicks     uint32 // the round ends after this many ticks
+	duration       int    // seconds of the next rounds, the config is shared with the arena and never changes
+	votes          chan vote
+	commands       chan func() // run by the tick goroutine, see call
+	jobs           jobQueue    // run by the job goroutine, see persist
+	pending        sync.WaitGroup
+	checkpoints    checkpointQueue
+	savedReplay    savedReplay
+	pausedAt       time.Time
+	onPause        func(route string, gp GamePause)
+	closing        bool // the server is shutting down, no more ticks nor rounds
+	botTicks       uint32
+	botWait        map[Camp]int // seconds each camp has been short of balls
+	frameNumber    uint32
+	frames         *frameEncoder
+	snapshot       atomic.Value // *Snapshot, see publish
+	campVotes      sync.Map
+
+	arenaID    string
 	dbGame     *model.Game
+	row        *gameRow // of dbGame, see persistRound
+	restart    *Restart // until the next round
 	ctx        context.Context
 	Map        Map `json:"map"`
 	GameStatus GameStatus
@@ -62,7 +109,6 @@ type Game struct {
 	Players sync.Map `json:"players"`
 	Items   sync.Map `json:"items"`
 
-	nextRoundChan  chan struct{}
 	stopSignalChan chan chan struct{}
 
 	toRewardName string
@@ -70,7 +116,7 @@ type Game struct {
 
 func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, sdkClient *zecreyface.Client,
 	onGameStart func(context.Context),
-	onGameStop func(context.Context),
+	onGameStop func(context.Context, GameStop),
 	onCampVotesChange func(camp Camp, votes int32)) *Game {
 
 	v := &Game{
@@ -78,6 +124,7 @@ func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, sdkClient *
 		db:                db,
 		sdkClient:         sdkClient,
 		cfg:               cfg,
+		duration:          cfg.GameDuration,
 		campVotes:         sync.Map{},
 		Players:           sync.Map{},
 		Items:             sync.Map{},
@@ -86,13 +133,25 @@ func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, sdkClient *
 		onCampVotesChange: onCampVotesChange,
 		GameStatus:        GameNotStarted,
 		stopSignalChan:    make(chan chan struct{}, 1),
-		nextRoundChan:     make(chan struct{}, 1),
+		votes:             make(chan vote, 256),
+		commands:          make(chan func(), 16),
+		jobs:              jobQueue{ready: make(chan struct{}, 1)},
+	}
+	v.frames = newFrameEncoder(cfg.KeyframeInterval, v.tickRate())
+
+	layout, err := LoadMapLayout(cfg.Map)
+	if err != nil {
+		panic(err)
 	}
+	v.layout = layout
 
 	zap.L().Debug("game init")
 
 	v.initMap()
 	v.resetRes()
+	v.seedRound(v.newRoundSeed())
+	v.publish()
+	go v.runJobs()
 	//v.Reset()
 
 	//v.AddPlayer(11111, BTC)
@@ -105,74 +164,208 @@ func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, sdkClient *
 }
 
 func (g *Game) initMap() {
-	_map := NewMap()
+	_map := NewMap(g.layout)
 	_space := &resolv.Space{}
 
 	_space = resolv.NewSpace(int(_map.W())+2*edgeWidth, int(_map.H())+2*edgeWidth, edgeWidth, edgeWidth)
 	_space.Add(resolv.NewObject(0, 0, _map.W()+edgeWidth, edgeWidth, EdgeTag, HorizontalEdgeTag))
-	_space.Add(resolv.NewObject(0, edgeWidth, edgeWidth, _map.W()+edgeWidth, EdgeTag, VerticalEdgeTag))
+	_space.Add(resolv.NewObject(0, edgeWidth, edgeWidth, _map.H()+edgeWidth, EdgeTag, VerticalEdgeTag))
 	_space.Add(resolv.NewObject(_map.W()+edgeWidth, 0, edgeWidth, _map.H()+edgeWidth, EdgeTag, VerticalEdgeTag))
 	_space.Add(resolv.NewObject(edgeWidth, _map.H()+edgeWidth, _map.W()+edgeWidth, edgeWidth, EdgeTag, HorizontalEdgeTag))
-
-	for y := 0; y < mapRow; y++ {
-		for x := 0; x < mapColumn; x++ {
-			camp := initCamp(x, y)
-			ox, oy := cellIndexToSpaceXY(x, y)
-			_space.Add(resolv.NewObject(ox, oy, float64(cellWidth), float64(cellHeight), CampTagMap[camp], CellTag, CellIndexToTag(x, y)))
+	cells := make([]*resolv.Object, 0, _map.Row*_map.Column)
+
+	for y := 0; y < _map.Row; y++ {
+		for x := 0; x < _map.Column; x++ {
+			ox, oy := _map.cellIndexToSpaceXY(x, y)
+			w, h := float64(_map.CellWidth), float64(_map.CellHeight)
+			switch _map.Tile(x, y) {
+			case TileWall:
+				_space.Add(resolv.NewObject(ox, oy, w, h, WallTag, CellIndexToTag(x, y)))
+				_map.Cells = append(_map.Cells, Empty)
+				cells = append(cells, nil)
+				continue
+			case TileHole:
+				_space.Add(resolv.NewObject(ox, oy, w, h, HoleTag, CellIndexToTag(x, y)))
+				_map.Cells = append(_map.Cells, Empty)
+				cells = append(cells, nil)
+				continue
+			}
+			camp := _map.initCamp(x, y)
+			obj := resolv.NewObject(ox, oy, w, h, CampTagMap[camp], CellTag, CellIndexToTag(x, y))
+			if _map.Tile(x, y) == TileBonus {
+				obj.AddTags(BonusTag)
+			}
+			_space.Add(obj)
 			_map.Cells = append(_map.Cells, camp)
+			cells = append(cells, obj)
 		}
 	}
 
 	g.Map = _map
 	g.space = _space
+	g.cells = cells
+	g.resetScores()
+	g.stats = map[uint64]*PlayerStats{}
+	g.pickups = map[ItemType]int{}
 	//fmt.Println("=== _map.Cells === :", len(_map.Cells))
 }
 
 func (g *Game) initGameInfo() {
-	g.dbGame = &model.Game{StartTime: time.Now(), EndTime: time.Now().Add(time.Duration(g.cfg.GameDuration) * time.Second)}
-	if err := g.db.Game.Create(g.dbGame); err != nil {
-		zap.L().Error("failed to create game", zap.Error(err))
+	g.dbGame = &model.Game{ArenaID: g.arenaID, StartTime: time.Now(), EndTime: time.Now().Add(time.Duration(g.duration) * time.Second), Seed: g.seed}
+	g.row, g.restart = nil, nil
+	if g.db == nil {
+		// headless games aren't stored
+		return
 	}
-	zap.L().Debug("game info init", zap.Uint("game_id", g.dbGame.ID))
+	row, dbGame := &gameRow{}, *g.dbGame
+	g.row = row
+	g.persist(func() {
+		if err := g.db.Game.Create(&dbGame); err != nil {
+			zap.L().Error("failed to create game", zap.Error(err))
+			return
+		}
+		row.id = dbGame.ID
+		zap.L().Debug("game info init", zap.Uint("game_id", dbGame.ID))
+		// the tick goroutine learns the id once it's there, the jobs of the round read it from row
+		id, created := dbGame.ID, dbGame.CreatedAt
+		select {
+		case g.commands <- func() {
+			if g.row == row {
+				g.dbGame.ID, g.dbGame.CreatedAt = id, created
+			}
+		}:
+		case <-g.ctx.Done():
+		}
+	})
+}
+
+// updateGame queues the write of the row of the round as it is now
+func (g *Game) updateGame() {
+	if g.db == nil || g.dbGame == nil {
+		return
+	}
+	dbGame := *g.dbGame
+	g.persistRound(func(id uint) {
+		dbGame.ID = id
+		if err := g.db.Game.Update(&dbGame); err != nil {
+			zap.L().Error("failed to update game", zap.Uint("game_id", id), zap.Error(err))
+		}
+	})
 }
 
 func (g *Game) resetRes() {
 	g.res = nil
 }
 
+// newRoundSeed returns the configured round seed, or a fresh one when it's not set
+func (g *Game) newRoundSeed() int64 {
+	if g.cfg.RoundSeed != 0 {
+		return g.cfg.RoundSeed
+	}
+	return time.Now().UnixNano()
+}
+
+// seedRound makes the rest of the round reproducible from seed
+func (g *Game) seedRound(seed int64) {
+	g.seed = seed
+	g.rngSource = newCountingSource(seed)
+	g.rng = rand.New(g.rngSource)
+	g.replay = newReplay(seed)
+	g.savedReplay = savedReplay{}
+}
+
+func (g *Game) GetSeed() int64 {
+	return g.seed
+}
+
+// GetGameID is the round of the last snapshot, safe to call from any goroutine
 func (g *Game) GetGameID() uint {
+	return g.Snapshot().GameID
+}
+
+func (g *Game) gameID() uint {
+	if g.dbGame == nil {
+		return 0
+	}
 	return g.dbGame.ID
 }
 
-func (g *Game) start() <-chan []byte {
+func (g *Game) start() <-chan Frame {
 	//wait for the first people enter,and then call the "StartRound" method
 	//g.stopSignalChan <- g.nextRoundChan
 	//now start
 	g.Reset()
-	gameTime := time.NewTimer(time.Duration(g.cfg.GameDuration) * time.Second)
-	gameTime.Stop()
-	stateChan := make(chan []byte)
+	if !g.restoreCheckpoint() && g.scheduled() {
+		g.enterLobby()
+	}
+	g.publish()
+	// the broadcaster may fall behind, the buffered frame is then the base of the next delta
+	// and the frames in between are simply never encoded, so the physics never wait for it
+	frameChan := make(chan Frame, 1)
 	go func() {
-		for {
+		tickInterval := time.Second / time.Duration(g.tickRate())
+		tickTicker := time.NewTicker(tickInterval)
+		defer tickTicker.Stop()
+		frameTicker := time.NewTicker(time.Second / time.Duration(g.cfg.FPS))
+		defer frameTicker.Stop()
 
-			if g.GameStatus == GameRunning {
-				g.Update()
-			}
-			s, _ := g.Serialize()
+		nextTick := time.Now()
+		for {
 			select {
-			case <-g.nextRoundChan:
-				gameTime.Reset(time.Duration(g.cfg.GameDuration) * time.Second)
 			case <-g.ctx.Done():
 				return
-			case <-gameTime.C:
-				gameTime.Stop()
-				g.endRound() //game ending
-			default:
-				stateChan <- s
+			case now := <-tickTicker.C:
+				// catch up on missed ticks so the simulation keeps its rate, unless it's hopelessly behind
+				for i := 0; !now.Before(nextTick) && i < maxCatchUpTicks; i++ {
+					g.step()
+					nextTick = nextTick.Add(tickInterval)
+				}
+				if now.Sub(nextTick) > tickInterval {
+					nextTick = now
+				}
+			case <-frameTicker.C:
+				if len(frameChan) < cap(frameChan) {
+					frameChan <- g.NextFrame()
+				}
 			}
 		}
 	}()
-	return stateChan
+	return frameChan
+}
+
+// step advances the simulation by one fixed tick
+func (g *Game) step() {
+	defer g.publish()
+	g.applyCommands()
+	g.applyVotes()
+	if g.closing {
+		return
+	}
+	g.schedule()
+	g.fillBots()
+	if g.GameStatus != GameRunning {
+		return
+	}
+	if g.advance() {
+		g.endRound() //game ending
+		return
+	}
+	g.saveCheckpoint()
+}
+
+func (g *Game) tickRate() int {
+	return TickRate(g.cfg)
+}
+
+// TickRate is how many ticks a second the games of cfg simulate, the frame rate when it isn't set
+func TickRate(cfg *config.Config) int {
+	if cfg.TickRate > 0 {
+		return cfg.TickRate
+	}
+	if cfg.FPS > 0 {
+		return cfg.FPS
+	}
+	return defaultTickRate
 }
 
 func (g *Game) endRound() {
@@ -182,17 +375,27 @@ func (g *Game) endRound() {
 	g.Save()
 	g.GameStatus = GameStopped
 	//g.stopSignalChan <- g.nextRoundChan
-	g.onGameStop(g.ctx)
-	_, err := g.sdkClient.MintNft(g.cfg.CollectionId, g.toRewardName,
-		fmt.Sprintf("%s%d", g.cfg.NftPrefix, time.Now().UnixMilli()),
-		fmt.Sprintf("zecrey MintNft %d", time.Now().UnixMilli()))
-	if err != nil {
-		zap.L().Error("MintNft", zap.Error(err))
+	if g.scheduled() {
+		g.setPhase(PhaseResults)
 	}
+	stop := g.gameStop()
+	g.persist(func() {
+		// the ranks are read after the round is saved so they count it
+		if g.db != nil {
+			stop.CampRank, _ = g.db.Camp.ListRank(3, CampModelIDs())
+			stop.PlayerRank, _ = g.db.Player.ListRank(3)
+		}
+		g.onGameStop(g.ctx, stop)
+	})
+	g.rewardRound()
 	//fmt.Println(fmt.Sprintf("MintNft success id:%v", nftInfo.Asset))
 	//zap.L().Debug(fmt.Sprintf("MintNft success id:%v", nftInfo.Asset.CollectionId))
 	// wait game to start
 	//<-time.After(time.Duration(g.cfg.GameRoundInterval) * time.Second)
+	if g.scheduled() {
+		// the map stays on screen with the results, the lobby resets it
+		return
+	}
 	g.Reset()
 	//
 	//g.AddPlayer(11111, BTC)
@@ -205,9 +408,29 @@ func (g *Game) endRound() {
 	//g.nextRoundChan <- struct{}{}
 }
 
-func (g *Game) StartRound(toRewardName string) {
+// StartRound starts a round on the tick goroutine and waits for it, the scheduled games only
+// remember who gets the reward
+func (g *Game) StartRound(toRewardName string) error {
+	return g.call(func() error {
+		g.startRound(toRewardName)
+		return nil
+	})
+}
+
+func (g *Game) startRound(toRewardName string) {
+	if g.closing {
+		return
+	}
+	if g.scheduled() {
+		// the scheduler starts the rounds, the first voter of the lobby gets the reward
+		if g.toRewardName == "" {
+			g.toRewardName = toRewardName
+		}
+		return
+	}
 	if g.GameStatus == GameStopped || g.GameStatus == GameNotStarted {
 		g.Reset()
+		g.seedRound(g.newRoundSeed())
 		g.toRewardName = toRewardName
 		g.GameStatus = GameRunning
 		//g.AddPlayer(11111, BTC)
@@ -215,106 +438,115 @@ func (g *Game) StartRound(toRewardName string) {
 		//g.AddPlayer(33333, BNB)
 		//g.AddPlayer(44444, AVAX)
 		//g.AddPlayer(55555, MATIC)
+		g.roundTicks = uint32(g.duration * g.tickRate())
 		g.initGameInfo()
+		g.publish()
 		g.onGameStart(g.ctx) //game start
-		g.nextRoundChan <- struct{}{}
 	}
 }
 
-/*
-	frame number: 4 bytes
-	map size: 4 bytes
-	map: map size bytes
-	player number: 4 bytes
-	players: 26 * len(players) bytes
-	item number: 4 bytes
-	items: 21 * items number bytes
-*/
+// Serialize encodes the whole game as a keyframe
 func (g *Game) Serialize() ([]byte, error) {
-	atomic.AddUint32(&g.frameNumber, 1)
-	bytesBuf := bytes.NewBuffer([]byte{})
-	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(b, g.frameNumber)
-	_, err := bytesBuf.Write(b)
-	if err != nil {
-		return bytesBuf.Bytes(), err
-	}
-
-	binary.BigEndian.PutUint32(b, g.Map.Size())
-	bytesBuf.Write(b)
-	bytesBuf.Write(g.Map.Serialize())
-
-	playerNumber := uint32(0)
-	var playerBytes []byte
-	g.Players.Range(func(key, value interface{}) bool { // O(N) call, but since players are not that many, it's fine
-		if v, ok := value.(*Player); ok && v != nil {
-			playerNumber++
-			playerBytes = append(playerBytes, v.Serialize()...)
-		}
-		return true
-	})
-	binary.BigEndian.PutUint32(b, playerNumber)
-	bytesBuf.Write(b)
-	bytesBuf.Write(playerBytes)
-
-	itemNumber := uint32(0)
-	var itemBytes []byte
-	g.Items.Range(func(key, value interface{}) bool { // O(N) call, but since items are not that many, it's fine
-		if v, ok := value.(*ItemObject); ok && v != nil {
-			itemNumber++
-			itemBytes = append(itemBytes, v.Serialize()...)
-		}
-		return true
-	})
-	binary.BigEndian.PutUint32(b, itemNumber)
-	bytesBuf.Write(b)
-	bytesBuf.Write(itemBytes)
-	return bytesBuf.Bytes(), nil
+	frameNumber := atomic.AddUint32(&g.frameNumber, 1)
+	return encodeKeyFrame(frameNumber, g.captureFrameState()), nil
 }
 
+// Save scores the round and queues its writes, the database is updated off the tick goroutine
 func (g *Game) Save() {
 	winner, _ := g.GetWinner()
+	if g.db == nil {
+		return
+	}
 	campID := uint8(winner)
 	g.dbGame.WinnerID = campID
+	g.dbGame.WinCondition = g.WinCondition()
+	g.dbGame.Draw = g.Draw()
 	g.dbGame.EndTime = time.Now()
-	if err := g.db.Game.Update(g.dbGame); err != nil {
-		zap.L().Error("failed to update game", zap.Error(err))
-	}
-	if err := g.db.Camp.IncreaseScore(campID); err != nil {
-		zap.L().Error("failed to increase camp score", zap.Error(err))
-	}
-	if err := g.db.Player.IncreaseScore(g.dbGame.ID, campID); err != nil {
-		zap.L().Error("failed to increase player score", zap.Error(err))
-	}
+	dbGame := *g.dbGame
+	winners, results, stats, replay := g.res.winners, g.CampResults(), g.PlayerStats(), g.replayRecord()
+	g.persistRound(func(id uint) {
+		// the round may end before the tick goroutine learns the id of its row
+		dbGame.ID = id
+		for i := range results {
+			results[i].GameID = id
+		}
+		for i := range stats {
+			stats[i].GameID = id
+		}
+		if replay != nil {
+			replay.GameID = id
+		}
+		if err := g.db.Game.Update(&dbGame); err != nil {
+			zap.L().Error("failed to update game", zap.Error(err))
+		}
+		// every camp sharing a draw scores
+		for _, camp := range winners {
+			if err := g.db.Camp.IncreaseScore(uint8(camp)); err != nil {
+				zap.L().Error("failed to increase camp score", zap.Error(err))
+			}
+			if err := g.db.Player.IncreaseScore(dbGame.ID, uint8(camp)); err != nil {
+				zap.L().Error("failed to increase player score", zap.Error(err))
+			}
+		}
+		if err := g.db.Result.Create(results); err != nil {
+			zap.L().Error("failed to save camp results", zap.Error(err))
+		}
+		if err := g.db.Stats.Create(stats); err != nil {
+			zap.L().Error("failed to save player stats", zap.Error(err))
+		}
+		if replay != nil {
+			if err := g.db.Replay.Create(replay); err != nil {
+				zap.L().Error("failed to save replay", zap.Uint("game_id", dbGame.ID), zap.Error(err))
+			}
+		}
+		g.deleteCheckpoint(dbGame.ID)
+	})
 }
 
 func (g *Game) GetWinner() (Camp, int) {
 	if g.res != nil {
 		return g.res.winner, g.res.score
 	}
-	score := make(map[Camp]int)
-	for _, v := range g.Map.Cells {
-		score[v]++
-	}
-	maxScore := 0
-	winner := BTC
-	for k, v := range score {
-		if v > maxScore && k != Empty {
-			maxScore = v
-			winner = k
+	if len(g.subRoundWins) > 0 {
+		winners, wins := g.subRoundWinners()
+		winner := Empty
+		if len(winners) == 1 {
+			winner = winners[0]
 		}
+		g.res = &res{winner: winner, winners: winners, score: wins, condition: WinBySubRounds}
+		return winner, wins
 	}
-	g.res = &res{winner: winner, score: maxScore}
+	tied, maxScore := leaders(g.campScores())
+	winners := g.breakTie(tied, g.reachedScoreAt)
+	// a draw has no single winner, Empty is stored instead
+	winner := Empty
+	if len(winners) == 1 {
+		winner = winners[0]
+	}
+	g.res = &res{winner: winner, winners: winners, score: maxScore, condition: WinByTimer}
 	return winner, maxScore
 }
+
+// Draw tells whether the round ended without a single winner
+func (g *Game) Draw() bool {
+	return g.res != nil && len(g.res.winners) != 1
+}
+
+// WinCondition is what decided the round, empty while it's running
+func (g *Game) WinCondition() string {
+	if g.res == nil {
+		return ""
+	}
+	return g.res.condition
+}
 func (g *Game) GetLastWinner() (uint8, error) {
-	last, err := g.db.Game.GetLastWinner()
+	last, err := g.db.Game.GetLastWinner(g.arenaID)
 	if err != nil {
 		zap.L().Error("failed to create game", zap.Error(err))
 		if err == gorm.ErrRecordNotFound {
-			return uint8(MATIC), nil
+			return uint8(Camps[len(Camps)-1].Camp), nil
 		}
-		return uint8(MATIC), err
+		return uint8(Camps[len(Camps)-1].Camp), err
 	}
 	return last.WinnerID, nil
 }
@@ -323,14 +555,45 @@ func (g *Game) Reset() {
 	g.campVotes = sync.Map{}
 	g.Items = sync.Map{}
 	g.frameNumber = 0
+	g.tick = 0
+	g.nextBallID = 0
+	g.nextItemID = 0
+	g.collisions = nil
+	g.events = nil
+	g.tickEvents = nil
+	g.captured = false
+	g.leader = Empty
+	g.subRoundWins = nil
+	g.frames.requestKeyFrame()
 	g.resetRes()
 	g.initMap()
 	g.GameStatus = GameNotStarted
 }
 
-func (g *Game) Update() {
+// rangePlayers calls f for every player ordered by id, unlike Players.Range the order is stable
+// so that collisions are resolved the same way in every run
+func (g *Game) rangePlayers(f func(player *Player) bool) {
+	var players []*Player
 	g.Players.Range(func(key, value interface{}) bool {
-		if player, ok := value.(*Player); ok && player != nil && player.playerObj != nil {
+		if v, ok := value.(*Player); ok && v != nil {
+			players = append(players, v)
+		}
+		return true
+	})
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	for _, p := range players {
+		if !f(p) {
+			return
+		}
+	}
+}
+
+func (g *Game) Update() {
+	g.rangePlayers(func(player *Player) bool {
+		if player.playerObj != nil {
+			g.updateEffects(player)
+		}
+		if player.playerObj != nil && !player.hasEffect(ItemFreeze) {
 			remainX, remainY := player.Vx, player.Vy
 
 			change := false
@@ -340,15 +603,32 @@ func (g *Game) Update() {
 					collisionObj := collision.Objects[0]
 					dx, dy = resolvDxDy(dx, dy, collision.ContactWithObject(collisionObj))
 					if collisionObj.HasTags(CellTag) {
-						remainX, remainY = player.rebound(dx, dy, remainX, remainY, collisionObj)
-						if !change {
-							change = true
-							x, y := GetCellIndex(collisionObj.Tags())
-							g.Map.Cells[y*mapColumn+x] = player.Camp
-							collisionObj.RemoveTags(removeCampTags(collisionObj.Tags())...)
-							collisionObj.AddTags(CampTagMap[player.Camp])
+						x, y := GetCellIndex(collisionObj.Tags())
+						if player.hasEffect(ItemShield) {
+							// the shield goes through the cell, it's captured on the way
+							player.removeEffect(ItemShield)
+							g.captureBy(player, x, y)
+							remainX -= dx
+							remainY -= dy
+						} else {
+							remainX, remainY = player.rebound(dx, dy, remainX, remainY, collisionObj)
+							g.countBounce(player)
+							if !change {
+								change = true
+								g.captureBy(player, x, y)
+							}
 						}
+					} else if collisionObj.HasTags(WallTag) {
+						remainX, remainY = player.rebound(dx, dy, remainX, remainY, collisionObj)
+						g.countBounce(player)
+					} else if collisionObj.HasTags(HoleTag) {
+						// the ball falls in and comes back at the center of its camp
+						player.playerObj.X, player.playerObj.Y = g.layout.cellIndexToSpaceXY(g.layout.Spawn(player.Camp))
+						dx, dy = 0, 0
+						remainX, remainY = 0, 0
 					} else if collisionObj.HasTags(EdgeTag) {
+						g.countBounce(player)
+						g.emitEdgeBounce(player)
 						if collisionObj.HasTags(HorizontalEdgeTag) {
 							player.Vy = -player.Vy
 							remainX -= dx
@@ -359,13 +639,16 @@ func (g *Game) Update() {
 							remainY -= dy
 						}
 					} else if collisionObj.HasTags(ItemTag) {
-						if collisionObj.HasTags(AcceleratorTag) {
-							player.Vx *= 1.5
-							player.Vy *= 1.5
-							remainX *= 1.5
-							remainY *= 1.5
-							id := itemTagsToId(collisionObj.Tags())
-							g.Items.Delete(id)
+						if t, ok := itemTagsToType(collisionObj.Tags()); ok {
+							g.countItem(player, t)
+							if effect := ItemMap[t].Effect; effect != nil {
+								remainX, remainY = effect.Apply(g, player, remainX, remainY)
+							}
+						}
+						if id, ok := itemTagsToId(collisionObj.Tags()); ok {
+							g.emitItem(EventItemCollected, id, player)
+							g.removeItem(id)
+						} else {
 							g.space.Remove(collisionObj)
 						}
 					}
@@ -382,13 +665,36 @@ func (g *Game) Update() {
 				player.playerObj.Y += dy
 				player.playerObj.Update()
 			}
-			g.Players.Store(key, player)
 		}
 		return true
 	})
+	g.collideBalls()
+	g.expireItems()
 	g.TryAddItem()
+	g.flushEvents()
+	g.tick++
+}
+
+// captureCell paints the cell in the camp, it returns the camp that held it and whether it changed
+func (g *Game) captureCell(x, y int, camp Camp) (Camp, bool) {
+	obj := g.cells[y*g.Map.Column+x]
+	if obj == nil {
+		return Empty, false
+	}
+	prev := g.Map.Cells[y*g.Map.Column+x]
+	if prev != camp {
+		v := g.layout.CellValue(y*g.Map.Column + x)
+		g.scores[prev] -= v
+		g.scores[camp] += v
+		g.reachScore(camp)
+	}
+	g.Map.Cells[y*g.Map.Column+x] = camp
+	obj.RemoveTags(removeCampTags(obj.Tags())...)
+	obj.AddTags(CampTagMap[camp])
+	return prev, prev != camp
 }
 
+// Size is the length of a keyframe of the game, see encodeKeyFrame
 func (g *Game) Size() uint32 {
 	pLen := uint32(0)
 	g.Players.Range(func(key, value interface{}) bool { // O(N) call, but since players are not that many, it's fine
@@ -397,7 +703,14 @@ func (g *Game) Size() uint32 {
 		}
 		return true
 	})
-	return 4 + 4 + g.Map.Size() + pLen
+	iLen := uint32(0)
+	g.Items.Range(func(key, value interface{}) bool {
+		if v, ok := value.(*ItemObject); ok && v != nil {
+			iLen += v.Size()
+		}
+		return true
+	})
+	return 4 + 4 + g.Map.Size() + 4 + pLen + 4 + iLen
 }
 
 func (g *Game) incrCampVotes(camp Camp) {
@@ -478,11 +791,9 @@ func space2MapXY(x, y float64) (float64, float64) {
 	return x - edgeWidth, y - edgeWidth
 }
 
-func cellIndexToSpaceXY(x, y int) (float64, float64) {
-	return float64(x*(cellWidth+lineWidth) + edgeWidth), float64(y*(cellHeight+lineWidth) + edgeWidth)
-}
-
 type res struct {
-	winner Camp
-	score  int
+	winner    Camp
+	winners   []Camp // several when the camps shared the win
+	score     int
+	condition string
 }
@@ -9,36 +9,32 @@ type GameInfo struct {
 	CampVotes      map[Camp]int32  `json:"camp_votes"`
 	CampRank       []model.Camp    `json:"camp_rank"`
 	PlayerRank     []model.Player  `json:"player_rank"`
-	WinnerId       uint8           `json:"winner_id"`
-	GameStatus     GameStatus      `json:"game_status"` //0 1 2 : 没开始，进行中，已结束
+	// ContributionRank ranks the players on the cells they captured and stole over every round
+	ContributionRank []model.PlayerContribution `json:"contribution_rank"`
+	WinnerId         uint8                      `json:"winner_id"`
+	Results          []model.CampResult         `json:"results"` // live cell counts of the round
+	Phase            PhaseChange                `json:"phase"`
+	GameStatus       GameStatus                 `json:"game_status"` //0 1 2 3 : 没开始，进行中，已结束，暂停
+	Restart          *Restart                   `json:"restart,omitempty"`
 }
 
+// GetGameInfo reads the game from its snapshot, safe to call from any goroutine
 func (g *Game) GetGameInfo() (GameInfo, error) {
 	var err error
-	var GameRound uint
-	if g.dbGame != nil {
-		GameRound = g.dbGame.ID
-	}
-
+	snap := g.Snapshot()
 	v := GameInfo{
-		Game:      g.dbGame,
-		GameRound: GameRound,
-		CampVotes: map[Camp]int32{},
+		Game:      snap.Game,
+		GameRound: snap.GameID,
+		CampVotes: snap.CampVotes,
 	}
 	offset, limit := 0, 100
-	v.HistoryMessage, err = g.db.Message.ListLatest(offset, limit)
+	v.HistoryMessage, err = g.db.Message.ListLatest(g.arenaID, offset, limit)
 	if err != nil {
 		return v, err
 	}
-	g.campVotes.Range(func(key, value interface{}) bool {
-		if c, ok := key.(Camp); ok && value.(*int32) != nil {
-			v.CampVotes[c] = *(value.(*int32))
-		}
-		return true
-	})
 
 	rankLimit := 3
-	v.CampRank, err = g.db.Camp.ListRank(rankLimit)
+	v.CampRank, err = g.db.Camp.ListRank(rankLimit, CampModelIDs())
 	if err != nil {
 		return v, err
 	}
@@ -47,29 +43,59 @@ func (g *Game) GetGameInfo() (GameInfo, error) {
 	if err != nil {
 		return v, err
 	}
-	v.GameStatus = g.GameStatus
+	v.ContributionRank, err = g.db.Stats.ListRank(rankLimit)
+	if err != nil {
+		return v, err
+	}
+	v.GameStatus = snap.GameStatus
+	v.Results = snap.Results
+	v.Phase = snap.Phase
+	v.Restart = snap.Restart
 	winnerId, _ := g.GetLastWinner()
 	v.WinnerId = winnerId
 	return v, nil
 }
 
 type GameStop struct {
-	Winner        Camp           `json:"winner"`
-	WinnerVotes   int64          `json:"winner_votes"`
-	NextCountDown int64          `json:"next_count_down"`
-	CampRank      []model.Camp   `json:"camp_rank"`
-	PlayerRank    []model.Player `json:"player_rank"`
+	Winner        Camp               `json:"winner"`
+	WinCondition  string             `json:"win_condition"`
+	Draw          bool               `json:"draw"`
+	Winners       []Camp             `json:"winners"`
+	Results       []model.CampResult `json:"results"`
+	WinnerVotes   int64              `json:"winner_votes"`
+	NextCountDown int64              `json:"next_count_down"`
+	CampRank      []model.Camp       `json:"camp_rank"`
+	PlayerRank    []model.Player     `json:"player_rank"`
+	// MVPs is the best contributor of each camp, TopContributors the best ones of the round
+	MVPs            []model.PlayerGameStats `json:"mvps"`
+	TopContributors []model.PlayerGameStats `json:"top_contributors"`
 }
 
-func (g *Game) GetGameStop() GameStop {
-	winner, _ := g.GetLastWinner()
+// gameStop builds the results of the round that just ended from the game itself, the ranks are left
+// to the job that saved it
+func (g *Game) gameStop() GameStop {
+	winner, _ := g.GetWinner()
 	v := GameStop{
-		Winner:        Camp(winner),
-		WinnerVotes:   g.db.Player.GetWinnerVotes(g.dbGame.ID, uint8(winner)),
+		Winner:        winner,
+		WinCondition:  g.WinCondition(),
+		Draw:          g.Draw(),
+		Results:       g.CampResults(),
 		NextCountDown: int64(g.cfg.GameRoundInterval),
 	}
-	rankLimit := 3
-	v.CampRank, _ = g.db.Camp.ListRank(rankLimit)
-	v.PlayerRank, _ = g.db.Player.ListRank(rankLimit)
+	if winner != Empty {
+		v.WinnerVotes = int64(g.campVoteCount(winner))
+	}
+	if g.scheduled() {
+		v.NextCountDown = int64(g.resultsTicks()+g.lobbyTicks()) / int64(g.tickRate())
+	}
+	if g.res != nil {
+		v.Winners = g.res.winners
+	}
+	stats := withoutBots(g.PlayerStats())
+	v.MVPs = MVPs(stats)
+	if len(stats) > topContributors {
+		stats = stats[:topContributors]
+	}
+	v.TopContributors = stats
 	return v
 }
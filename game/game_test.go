@@ -38,10 +38,21 @@ func Rect(x1, y1, x2, y2 int) {
 	VLine(x2, y1, y2)
 }
 
+// newTestGame returns a game that never touches the database or the nft sdk
+func newTestGame(cfg *config.Config) *Game {
+	if cfg.ItemFrameChance == 0 {
+		cfg.ItemFrameChance = 500
+	}
+	return NewGame(context.Background(), cfg, nil, nil, func(context.Context) {}, func(context.Context, GameStop) {}, func(camp Camp, votes int32) {})
+}
+
 func TestGame(t *testing.T) {
+	if _, err := os.Stat("../config/local.json"); err != nil {
+		t.Skip("../config/local.json not found")
+	}
 	cfg := config.Read("../config/local.json")
 	d := db.NewClient(cfg.Database)
-	g := NewGame(context.Background(), cfg, d, func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g := NewGame(context.Background(), cfg, d, nil, func(context.Context) {}, func(context.Context, GameStop) {}, func(camp Camp, votes int32) {})
 
 	new_png_file := "draw.png" // output image will live here
 
@@ -65,3 +76,43 @@ func TestGame(t *testing.T) {
 	defer myfile.Close()
 	png.Encode(myfile, myimage)
 }
+
+type testVote struct {
+	frame    int
+	playerID uint64
+	camp     Camp
+}
+
+// playRound replays a vote timeline and returns the final cells
+func playRound(seed int64, frames int, votes []testVote) []Camp {
+	g := newTestGame(&config.Config{ItemFrameChance: 50})
+	g.seedRound(seed)
+	g.GameStatus = GameRunning
+	for i := 0; i < frames; i++ {
+		for _, v := range votes {
+			if v.frame == i {
+				g.AddPlayer(v.playerID, v.camp)
+			}
+		}
+		g.Update()
+	}
+	return g.Map.Cells
+}
+
+func TestDeterministicRound(t *testing.T) {
+	votes := []testVote{
+		{0, 1, BTC}, {0, 2, ETH}, {0, 3, BNB},
+		{10, 4, AVAX}, {10, 5, MATIC},
+		{200, 6, BTC}, {200, 7, ETH},
+		{500, 8, BNB},
+	}
+	a := playRound(42, 1500, votes)
+	b := playRound(42, 1500, votes)
+	if !equalCells(a, b) {
+		t.Fatal("same seed and votes gave different cells")
+	}
+	c := playRound(43, 1500, votes)
+	if equalCells(a, c) {
+		t.Fatal("different seeds gave the same cells")
+	}
+}
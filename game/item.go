@@ -4,8 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"math/rand"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/solarlune/resolv"
 )
@@ -16,47 +17,113 @@ const (
 	itemPixelR = 15
 
 	ItemAccelerator ItemType = iota
-	ItemTag                  = "ITEM"
+	ItemShield
+	ItemGrow
+	ItemSplit
+	ItemFreeze
+	ItemPaintBomb
+	ItemTag = "ITEM"
 
 	AcceleratorTag = "Accelerator"
+	ShieldTag      = "Shield"
+	GrowTag        = "Grow"
+	SplitTag       = "Split"
+	FreezeTag      = "Freeze"
+	PaintBombTag   = "PaintBomb"
 
-	KindsOfItems = 1
+	KindsOfItems = 6
+
+	defaultMaxItems   = 5
+	defaultItemTTL    = 15 // seconds
+	maxItemSpawnTries = 10
 )
 
 var (
-	ItemTagMap = map[ItemType]string{
-		ItemAccelerator: AcceleratorTag,
-	}
+	ItemTagMap        = map[ItemType]string{}
+	ItemTagMapReverse = map[string]ItemType{}
+	ItemMap           = map[ItemType]Item{}
 
-	ItemTagMapReverse = map[string]ItemType{
-		AcceleratorTag: ItemAccelerator,
-	}
+	// AllItems is sent to the clients when they join, items spawn in this order of weights
+	AllItems = []Item{}
 
 	Accelerator = Item{
 		Type:      ItemAccelerator,
 		Name:      AcceleratorTag,
 		Thumbnail: "https://res.cloudinary.com/zecrey/image/upload/v1665310283/1054990_rocket_spacecraft_spaceship_icon_gtia85.jpg",
+		Weight:    40,
+		Effect:    accelerator{},
 	}
-	ItemMap = map[ItemType]Item{
-		ItemAccelerator: Accelerator,
+	Shield = Item{
+		Type:   ItemShield,
+		Name:   ShieldTag,
+		Weight: 15,
+		Effect: shield{},
 	}
-
-	AllItems = []Item{
-		Accelerator,
+	Grow = Item{
+		Type:   ItemGrow,
+		Name:   GrowTag,
+		Weight: 15,
+		Effect: grow{},
+	}
+	Split = Item{
+		Type:   ItemSplit,
+		Name:   SplitTag,
+		Weight: 10,
+		Effect: split{},
+	}
+	Freeze = Item{
+		Type:   ItemFreeze,
+		Name:   FreezeTag,
+		Weight: 10,
+		Effect: freeze{},
+	}
+	PaintBomb = Item{
+		Type:   ItemPaintBomb,
+		Name:   PaintBombTag,
+		Weight: 10,
+		Effect: paintBomb{},
 	}
 )
 
+func init() {
+	for _, item := range []Item{Accelerator, Shield, Grow, Split, Freeze, PaintBomb} {
+		RegisterItem(item)
+	}
+}
+
 type Item struct {
 	Type      ItemType `json:"type"`
 	Name      string   `json:"name"`
 	Thumbnail string   `json:"thumbnail"`
+	Weight    int      `json:"weight"` // chance to spawn relative to the other items, 0 never spawns
+
+	Effect ItemEffect `json:"-"`
+}
+
+// ItemEffect is what an item does to the ball picking it up, it returns the movement left for this tick
+type ItemEffect interface {
+	Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64)
+}
+
+// RegisterItem makes a new kind of item spawn in every game, it must be called before the games start
+func RegisterItem(item Item) {
+	if _, ok := ItemMap[item.Type]; ok {
+		panic(fmt.Sprintf("item type %d registered twice", item.Type))
+	}
+	ItemTagMap[item.Type] = item.Name
+	ItemTagMapReverse[item.Name] = item.Type
+	ItemMap[item.Type] = item
+	AllItems = append(AllItems, item)
 }
 
 type ItemObject struct {
-	Id   uint32
-	X    float64
-	Y    float64
-	Item Item
+	Id         uint32
+	X          float64
+	Y          float64
+	Item       Item
+	ExpireTick uint32 // the item disappears at this tick if nobody picked it up
+
+	obj *resolv.Object
 }
 
 func (p *ItemObject) Serialize() []byte {
@@ -69,6 +136,11 @@ func (p *ItemObject) Serialize() []byte {
 	return bytesBuffer.Bytes()
 }
 
+// Size is the length of Serialize: id 4 bytes, type 1 byte, x and y 8 bytes each
+func (p *ItemObject) Size() uint32 {
+	return 21
+}
+
 func (i *ItemObject) Center() (float64, float64) {
 	return i.X + float64(itemPixelR), i.Y + float64(itemPixelR)
 }
@@ -77,29 +149,164 @@ func itemIdToTag(Id uint32) string {
 	return fmt.Sprintf("%s_%d", ItemTag, Id)
 }
 
-func itemTagsToId(tags []string) uint32 {
-	var id uint32
+func itemTagsToId(tags []string) (uint32, bool) {
+	prefix := ItemTag + "_"
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, prefix) {
+			if id, err := strconv.ParseUint(tag[len(prefix):], 10, 32); err == nil {
+				return uint32(id), true
+			}
+		}
+	}
+	return 0, false
+}
 
+func itemTagsToType(tags []string) (ItemType, bool) {
 	for _, tag := range tags {
-		if tag[:len(ItemTag)] == ItemTag {
-			fmt.Sscanf(tag, "%s_%d", &id)
+		if t, ok := ItemTagMapReverse[tag]; ok {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// itemWeight is the weight of the item in this game, the config can override the default one
+func (g *Game) itemWeight(item Item) int {
+	if w, ok := g.cfg.ItemWeights[item.Name]; ok {
+		return w
+	}
+	return item.Weight
+}
+
+// randomItem picks an item according to the weights, false when every weight is 0
+func (g *Game) randomItem() (Item, bool) {
+	total := 0
+	for _, item := range AllItems {
+		total += g.itemWeight(item)
+	}
+	if total <= 0 {
+		return Item{}, false
+	}
+	n := g.rng.Intn(total)
+	for _, item := range AllItems {
+		if n < g.itemWeight(item) {
+			return item, true
 		}
+		n -= g.itemWeight(item)
 	}
-	return id
+	return Item{}, false
 }
 
 func (g *Game) TryAddItem() {
-	if g.GameStatus != GameRunning || rand.Intn(g.cfg.ItemFrameChance) != 1 {
+	if g.GameStatus != GameRunning || g.rng.Intn(g.cfg.ItemFrameChance) != 1 {
+		return
+	}
+	if g.liveItems() >= g.maxItems() {
+		return
+	}
+	kind, ok := g.randomItem()
+	if !ok {
+		return
+	}
+	x, y, ok := g.itemSpawnXY()
+	if !ok {
 		return
 	}
-	x, y := g.Map.RandomSpaceXY()
-	g.space.Add(resolv.NewObject(x, y, float64(2*itemPixelR), float64(2*itemPixelR), ItemTag, ItemTagMap[ItemAccelerator]))
+	g.nextItemID++
 	item := &ItemObject{
-		Id:   uint32(time.Now().UnixMilli()),
-		X:    x,
-		Y:    y,
-		Item: ItemMap[ItemAccelerator],
+		Id:         g.nextItemID,
+		X:          x,
+		Y:          y,
+		Item:       kind,
+		ExpireTick: g.tick + g.secondsToTicks(g.itemTTL()),
+	}
+	item.obj = resolv.NewObject(x, y, float64(2*itemPixelR), float64(2*itemPixelR), ItemTag, ItemTagMap[kind.Type], itemIdToTag(item.Id))
+	g.space.Add(item.obj)
+
+	g.Items.Store(item.Id, item)
+	g.replay.recordItem(g.tick, item)
+	g.emitItem(EventItemSpawned, item.Id, nil)
+}
+
+// itemSpawnXY picks a free cell for an item, false when the random picks all landed on a wall, a hole, a ball
+// or another item
+func (g *Game) itemSpawnXY() (float64, float64, bool) {
+	size := float64(2 * itemPixelR)
+	for i := 0; i < maxItemSpawnTries; i++ {
+		x, y, ok := g.Map.RandomSpaceXY(g.rng, size, size)
+		if !ok {
+			continue
+		}
+		free := true
+		g.Players.Range(func(key, value interface{}) bool {
+			if p, ok := value.(*Player); ok && p.playerObj != nil {
+				free = !overlap(x, y, size, size, p.playerObj.X, p.playerObj.Y, p.playerObj.W, p.playerObj.H)
+			}
+			return free
+		})
+		g.Items.Range(func(key, value interface{}) bool {
+			if item, ok := value.(*ItemObject); ok && free {
+				free = !overlap(x, y, size, size, item.X, item.Y, size, size)
+			}
+			return free
+		})
+		if free {
+			return x, y, true
+		}
 	}
+	return 0, 0, false
+}
+
+func overlap(x1, y1, w1, h1, x2, y2, w2, h2 float64) bool {
+	return x1 < x2+w2 && x2 < x1+w1 && y1 < y2+h2 && y2 < y1+h1
+}
 
-	g.Items.LoadOrStore(item.Id, item)
+// removeItem takes the item out of the game, after a pickup or when it expires
+func (g *Game) removeItem(id uint32) {
+	v, ok := g.Items.LoadAndDelete(id)
+	if !ok {
+		return
+	}
+	if item, ok := v.(*ItemObject); ok && item.obj != nil {
+		g.space.Remove(item.obj)
+	}
+}
+
+func (g *Game) expireItems() {
+	var expired []uint32
+	g.Items.Range(func(key, value interface{}) bool {
+		if item, ok := value.(*ItemObject); ok && item.ExpireTick <= g.tick {
+			expired = append(expired, item.Id)
+		}
+		return true
+	})
+	// in id order, the events come out the same in every run
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	for _, id := range expired {
+		g.emitItem(EventItemExpired, id, nil)
+		g.removeItem(id)
+	}
+}
+
+func (g *Game) liveItems() int {
+	n := 0
+	g.Items.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (g *Game) maxItems() int {
+	if g.cfg.MaxItems > 0 {
+		return g.cfg.MaxItems
+	}
+	return defaultMaxItems
+}
+
+func (g *Game) itemTTL() int {
+	if g.cfg.ItemTTL > 0 {
+		return g.cfg.ItemTTL
+	}
+	return defaultItemTTL
 }
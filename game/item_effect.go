@@ -0,0 +1,89 @@
+package game
+
+import (
+	"math"
+)
+
+const (
+	acceleratorFactor = 1.5
+	grownPixelR       = 9
+	paintBombRadius   = 3 // cells
+
+	splitBallIDBase = 1 << 63 // balls split from a player get ids above this one
+)
+
+// accelerator makes the ball 1.5 times faster for a few seconds
+type accelerator struct{}
+
+func (accelerator) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	p.addEffect(ItemAccelerator, g.tick+g.secondsToTicks(accelerateSeconds))
+	return remainX, remainY
+}
+
+// shield lets the ball go through the next enemy cell, capturing it without bouncing
+type shield struct{}
+
+func (shield) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	p.addEffect(ItemShield, 0)
+	return remainX, remainY
+}
+
+// grow makes the ball bigger for a few seconds
+type grow struct{}
+
+func (grow) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	p.resize(grownPixelR)
+	p.addEffect(ItemGrow, g.tick+g.secondsToTicks(growSeconds))
+	return remainX, remainY
+}
+
+// split spawns a second ball of the same camp going the perpendicular way
+type split struct{}
+
+func (split) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	g.nextBallID++
+	ball := g.addBall(splitBallIDBase+g.nextBallID, p.Camp, p.playerObj.X, p.playerObj.Y, p.Vy, -p.Vx)
+	ball.resize(p.R)
+	ball.playerObj.X, ball.playerObj.Y = p.playerObj.X, p.playerObj.Y
+	ball.playerObj.Update()
+	ball.effects = append([]Effect{}, p.effects...)
+	ball.owner = p.ID
+	if p.owner != 0 {
+		ball.owner = p.owner
+	}
+	return remainX, remainY
+}
+
+// freeze stops the balls of the other camps for a few seconds
+type freeze struct{}
+
+func (freeze) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	until := g.tick + g.secondsToTicks(freezeSeconds)
+	g.Players.Range(func(key, value interface{}) bool {
+		if other, ok := value.(*Player); ok && other.Camp != p.Camp {
+			other.addEffect(ItemFreeze, until)
+		}
+		return true
+	})
+	return remainX, remainY
+}
+
+// paintBomb captures every cell around the ball
+type paintBomb struct{}
+
+func (paintBomb) Apply(g *Game, p *Player, remainX, remainY float64) (float64, float64) {
+	cx, cy := p.GetCenter()
+	x := int((cx - edgeWidth) / float64(g.Map.CellWidth+lineWidth))
+	y := int((cy - edgeWidth) / float64(g.Map.CellHeight+lineWidth))
+	for j := y - paintBombRadius; j <= y+paintBombRadius; j++ {
+		for i := x - paintBombRadius; i <= x+paintBombRadius; i++ {
+			if i < 0 || j < 0 || i >= g.Map.Column || j >= g.Map.Row {
+				continue
+			}
+			if math.Hypot(float64(i-x), float64(j-y)) <= paintBombRadius {
+				g.captureBy(p, i, j)
+			}
+		}
+	}
+	return remainX, remainY
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestItemWeights(t *testing.T) {
+	g := newTestGame(&config.Config{ItemWeights: map[string]int{AcceleratorTag: 0, ShieldTag: 0, GrowTag: 0, SplitTag: 0, PaintBombTag: 0}})
+	for i := 0; i < 100; i++ {
+		if item, ok := g.randomItem(); !ok || item.Type != ItemFreeze {
+			t.Fatalf("picked %+v", item)
+		}
+	}
+	g.cfg.ItemWeights[FreezeTag] = 0
+	if _, ok := g.randomItem(); ok {
+		t.Fatal("picked an item with every weight at 0")
+	}
+}
+
+func TestItemEffects(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30})
+	g.GameStatus = GameRunning
+	btc := g.AddPlayer(1, BTC)
+	eth := g.AddPlayer(2, ETH)
+
+	Grow.Effect.Apply(g, btc, 0, 0)
+	if btc.R != grownPixelR || btc.playerObj.W != float64(2*btc.R) {
+		t.Fatalf("grown ball has r %d and width %v", btc.R, btc.playerObj.W)
+	}
+
+	Split.Effect.Apply(g, btc, 0, 0)
+	v, ok := g.Players.Load(uint64(splitBallIDBase + 1))
+	if !ok || v.(*Player).Camp != BTC || v.(*Player).R != btc.R {
+		t.Fatal("split ball not added")
+	}
+
+	Freeze.Effect.Apply(g, btc, 0, 0)
+	x, y := eth.playerObj.X, eth.playerObj.Y
+	g.Update()
+	if eth.playerObj.X != x || eth.playerObj.Y != y {
+		t.Fatal("frozen ball moved")
+	}
+
+	Shield.Effect.Apply(g, btc, 0, 0)
+	if !btc.hasEffect(ItemShield) {
+		t.Fatal("no shield")
+	}
+
+	PaintBomb.Effect.Apply(g, eth, 0, 0)
+	captured := 0
+	for _, c := range g.Map.Cells {
+		if c == ETH {
+			captured++
+		}
+	}
+	if captured <= 5 {
+		t.Fatalf("paint bomb captured %d cells", captured)
+	}
+}
+
+func TestTimedEffects(t *testing.T) {
+	// no item spawns, a ball picking one up would get new effects
+	g := newTestGame(&config.Config{FPS: 30, MaxVelocity: 36, ItemFrameChance: math.MaxInt32})
+	g.GameStatus = GameRunning
+	p := g.AddPlayer(1, BTC)
+
+	for i := 0; i < 3; i++ {
+		Accelerator.Effect.Apply(g, p, 0, 0)
+	}
+	Grow.Effect.Apply(g, p, 0, 0)
+	g.Update()
+	if v := math.Hypot(p.Vx, p.Vy); math.Abs(v-1.2) > 1e-9 {
+		t.Fatalf("speed is %v over the cap", v)
+	}
+	if len(p.effects) != 2 {
+		t.Fatalf("effects %+v", p.effects)
+	}
+	frames := newFrameEncoder(10, g.tickRate())
+	if f := frames.encode(1, g.captureFrameState()); f.Effects == nil {
+		t.Fatal("keyframe without effects")
+	}
+	if f := frames.encode(2, g.captureFrameState()); f.Effects != nil {
+		t.Fatal("unchanged effects sent again")
+	}
+
+	for g.tick <= g.secondsToTicks(growSeconds) {
+		g.Update()
+	}
+	if len(p.effects) != 0 || p.R != defaultPlayerPixelR {
+		t.Fatalf("effects %+v and r %d after they expired", p.effects, p.R)
+	}
+	if v := math.Hypot(p.Vx, p.Vy); math.Abs(v*30-playerInitialVelocity) > 1e-9 {
+		t.Fatalf("speed is %v after the accelerator expired", v)
+	}
+	if f := frames.encode(3, g.captureFrameState()); f.Effects == nil {
+		t.Fatal("expired effects not sent")
+	}
+}
+
+func TestSpeedPerSecond(t *testing.T) {
+	for _, rate := range []int{30, 60, 120} {
+		g := newTestGame(&config.Config{FPS: 30, TickRate: rate})
+		g.GameStatus = GameRunning
+		p := g.AddPlayer(1, BTC)
+		Accelerator.Effect.Apply(g, p, 0, 0)
+		g.Update()
+		if v := math.Hypot(p.Vx, p.Vy) * float64(rate); math.Abs(v-playerInitialVelocity*acceleratorFactor) > 1e-9 {
+			t.Fatalf("tick rate %d: %v pixels per second", rate, v)
+		}
+	}
+}
+
+func TestItemLifecycle(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, ItemFrameChance: 2, ItemTTL: 1, MaxItems: 3})
+	g.GameStatus = GameRunning
+	for g.liveItems() < 3 {
+		g.TryAddItem()
+	}
+	for i := 0; i < 50; i++ {
+		g.TryAddItem()
+	}
+	if g.liveItems() != 3 || g.nextItemID != 3 {
+		t.Fatalf("%d live items, last id %d", g.liveItems(), g.nextItemID)
+	}
+	g.Items.Range(func(key, value interface{}) bool {
+		item := value.(*ItemObject)
+		if id, ok := itemTagsToId(item.obj.Tags()); !ok || id != item.Id {
+			t.Fatalf("item %d tagged as %d", item.Id, id)
+		}
+		g.Items.Range(func(_, other interface{}) bool {
+			o := other.(*ItemObject)
+			if o != item && overlap(item.X, item.Y, 2*itemPixelR, 2*itemPixelR, o.X, o.Y, 2*itemPixelR, 2*itemPixelR) {
+				t.Fatalf("items %d and %d overlap", item.Id, o.Id)
+			}
+			return true
+		})
+		return true
+	})
+
+	g.removeItem(2)
+	if _, ok := g.Items.Load(uint32(2)); ok {
+		t.Fatal("item 2 not removed")
+	}
+	g.cfg.ItemFrameChance = 1 << 30
+	for i := uint32(0); i <= g.secondsToTicks(1); i++ {
+		g.Update()
+	}
+	if n := g.liveItems(); n != 0 {
+		t.Fatalf("%d items left after their ttl", n)
+	}
+}
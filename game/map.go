@@ -1,61 +1,250 @@
 package game
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"math/rand"
+	"os"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
 )
 
 const (
-	mapRow     = 30
-	mapColumn  = 40
-	cellWidth  = 20
-	cellHeight = 20
-	lineWidth  = 1
+	defaultMapRow     = 30
+	defaultMapColumn  = 40
+	defaultCellWidth  = 20
+	defaultCellHeight = 20
+	lineWidth         = 1
+
+	maxMapCells = 1 << 16 // cell indexes are 2 bytes in delta frames
 )
 
+// MapLayout is the shape of the map, it's loaded once and every round starts from it
+type MapLayout struct {
+	Row        int
+	Column     int
+	CellWidth  int
+	CellHeight int
+	Spawns     map[Camp][2]int // camp -> cell column, row of the center of the camp
+	Tiles      []Tile          // one per cell, nil when the map has no special tiles
+	BonusValue int
+}
+
 type Map struct {
+	*MapLayout
 	Cells []Camp `json:"cells"`
 }
 
-func NewMap() Map {
+func NewMap(layout *MapLayout) Map {
 	return Map{
-		Cells: []Camp{},
+		MapLayout: layout,
+		Cells:     []Camp{},
 	}
 }
 
+// LoadMapLayout builds the layout from the map file and the config, and checks every camp of the registry spawns inside the map
+func LoadMapLayout(cfg config.MapConfig) (*MapLayout, error) {
+	l := &MapLayout{Spawns: map[Camp][2]int{}}
+	if cfg.File != "" {
+		b, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		var file config.MapConfig
+		if err := json.Unmarshal(b, &file); err != nil {
+			return nil, fmt.Errorf("map file %s: %w", cfg.File, err)
+		}
+		if cfg.Row == 0 {
+			cfg.Row = file.Row
+		}
+		if cfg.Column == 0 {
+			cfg.Column = file.Column
+		}
+		if cfg.CellWidth == 0 {
+			cfg.CellWidth = file.CellWidth
+		}
+		if cfg.CellHeight == 0 {
+			cfg.CellHeight = file.CellHeight
+		}
+		if cfg.Grid == nil {
+			cfg.Grid = file.Grid
+		}
+		if cfg.BonusValue == 0 {
+			cfg.BonusValue = file.BonusValue
+		}
+		for tag, spawn := range file.Spawns {
+			if _, ok := cfg.Spawns[tag]; !ok {
+				if cfg.Spawns == nil {
+					cfg.Spawns = map[string][2]int{}
+				}
+				cfg.Spawns[tag] = spawn
+			}
+		}
+	}
+
+	if cfg.Grid != nil {
+		row, column, tiles, err := parseTileGrid(cfg.Grid)
+		if err != nil {
+			return nil, err
+		}
+		if (cfg.Row != 0 && cfg.Row != row) || (cfg.Column != 0 && cfg.Column != column) {
+			return nil, fmt.Errorf("map grid is %dx%d but the map is %dx%d", column, row, cfg.Column, cfg.Row)
+		}
+		cfg.Row, cfg.Column = row, column
+		l.Tiles = tiles
+	}
+	l.Row = defaultInt(cfg.Row, defaultMapRow)
+	l.Column = defaultInt(cfg.Column, defaultMapColumn)
+	l.CellWidth = defaultInt(cfg.CellWidth, defaultCellWidth)
+	l.CellHeight = defaultInt(cfg.CellHeight, defaultCellHeight)
+	l.BonusValue = defaultInt(cfg.BonusValue, defaultBonusValue)
+	if l.Row < 3 || l.Column < 3 || l.Row*l.Column > maxMapCells {
+		return nil, fmt.Errorf("map of %dx%d cells is out of range", l.Column, l.Row)
+	}
+	if l.CellWidth < minCellSize || l.CellHeight < minCellSize {
+		return nil, fmt.Errorf("cells of %dx%d are smaller than %d", l.CellWidth, l.CellHeight, minCellSize)
+	}
+	for tag, spawn := range cfg.Spawns {
+		camp, ok := CampTagMapReverse[tag]
+		if !ok || camp == Empty {
+			return nil, fmt.Errorf("spawn of unknown camp %s", tag)
+		}
+		l.Spawns[camp] = spawn
+	}
+	for _, info := range Camps {
+		camp := info.Camp
+		if _, ok := l.Spawns[camp]; !ok {
+			if info.spawn != nil {
+				l.Spawns[camp] = *info.spawn
+			} else {
+				x, y := camp.CenterCellIndex(l.Row, l.Column)
+				l.Spawns[camp] = [2]int{x, y}
+			}
+		}
+		// the camp starts as a cross around its center
+		x, y := l.Spawn(camp)
+		if x < 1 || x > l.Column-2 || y < 1 || y > l.Row-2 {
+			return nil, fmt.Errorf("spawn %d,%d of camp %s is out of the map", x, y, CampTagMap[camp])
+		}
+		if !l.capturable(x, y) || !l.capturable(x-1, y) || !l.capturable(x+1, y) || !l.capturable(x, y-1) || !l.capturable(x, y+1) {
+			return nil, fmt.Errorf("spawn %d,%d of camp %s is on a wall or a hole", x, y, CampTagMap[camp])
+		}
+	}
+	for a, sa := range l.Spawns {
+		for b, sb := range l.Spawns {
+			if a < b && abs(sa[0]-sb[0])+abs(sa[1]-sb[1]) <= 2 {
+				return nil, fmt.Errorf("spawns of camps %s and %s overlap", CampTagMap[a], CampTagMap[b])
+			}
+		}
+	}
+	return l, nil
+}
+
+// mapConfig gives back the layout without the map file
+func (l *MapLayout) mapConfig() config.MapConfig {
+	cfg := config.MapConfig{
+		Row:        l.Row,
+		Column:     l.Column,
+		CellWidth:  l.CellWidth,
+		CellHeight: l.CellHeight,
+		Spawns:     map[string][2]int{},
+		BonusValue: l.BonusValue,
+	}
+	for camp, spawn := range l.Spawns {
+		cfg.Spawns[CampTagMap[camp]] = spawn
+	}
+	if l.Tiles != nil {
+		chars := map[Tile]rune{}
+		for c, t := range tileChars {
+			chars[t] = c
+		}
+		for y := 0; y < l.Row; y++ {
+			row := make([]rune, l.Column)
+			for x := range row {
+				row[x] = chars[l.Tile(x, y)]
+			}
+			cfg.Grid = append(cfg.Grid, string(row))
+		}
+	}
+	return cfg
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func defaultInt(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Spawn returns the cell index of the center of the camp
+func (l *MapLayout) Spawn(camp Camp) (int, int) {
+	if s, ok := l.Spawns[camp]; ok {
+		return s[0], s[1]
+	}
+	return camp.CenterCellIndex(l.Row, l.Column)
+}
+
+func (l *MapLayout) cellIndexToSpaceXY(x, y int) (float64, float64) {
+	return float64(x*(l.CellWidth+lineWidth) + edgeWidth), float64(y*(l.CellHeight+lineWidth) + edgeWidth)
+}
+
 func (m *Map) W() float64 {
-	return float64(mapColumn * (cellWidth + lineWidth))
+	return float64(m.Column * (m.CellWidth + lineWidth))
 }
 
 func (m *Map) H() float64 {
-	return float64(mapRow * (cellHeight + lineWidth))
+	return float64(m.Row * (m.CellHeight + lineWidth))
 }
 
 func (m *Map) Serialize() []byte {
-	l := len(m.Cells) * sizeOfCellStateBits / 8 //8v 16个字节
-	res := make([]byte, l)
-	offset := 0
-	for i := 0; i < len(m.Cells); i += 2 {
-		n := byte(m.Cells[i]<<4) & campMaskLeft
-		if i+1 < len(m.Cells) {
-			n = n | (byte(m.Cells[i+1]) & campMaskRight)
-		}
-		res[offset] = n
-		offset++
-	}
-	//fmt.Println(hex.EncodeToString(res))
-	return res
+	//8v 16个字节
+	return packNibbles(len(m.Cells), func(i int) byte { return byte(m.Cells[i]) })
 }
 
 func (m *Map) Size() uint32 {
-	return uint32(len(m.Cells) * sizeOfCellStateBits / 8)
+	return uint32((len(m.Cells)*sizeOfCellStateBits + 7) / 8)
 }
 
 func (m *Map) OutofMap(x, y float64) bool {
 	return x < 0 || x > m.W() || y < 0 || y > m.H()
 }
 
-func (m *Map) RandomSpaceXY() (float64, float64) {
-	x := rand.Intn(mapColumn)*(cellWidth+lineWidth) + edgeWidth
-	y := rand.Intn(mapRow)*(cellHeight+lineWidth) + edgeWidth
-	return float64(x), float64(y)
+// RandomSpaceXY returns the position of an object of w by h pixels put on a random cell and moved inside
+// the map, false when 10 picks in a row had it over a wall or a hole
+func (m *Map) RandomSpaceXY(rng *rand.Rand, w, h float64) (float64, float64, bool) {
+	for i := 0; i < 10; i++ {
+		x, y := m.cellIndexToSpaceXY(rng.Intn(m.Column), rng.Intn(m.Row))
+		x = math.Min(x, edgeWidth+m.W()-w)
+		y = math.Min(y, edgeWidth+m.H()-h)
+		if x >= edgeWidth && y >= edgeWidth && m.capturableSpace(x, y, w, h) {
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
+// capturableSpace tells whether every cell under the w by h pixels at x,y is capturable
+func (m *Map) capturableSpace(x, y, w, h float64) bool {
+	cw, ch := float64(m.CellWidth+lineWidth), float64(m.CellHeight+lineWidth)
+	x0, y0 := int(math.Floor((x-edgeWidth)/cw)), int(math.Floor((y-edgeWidth)/ch))
+	x1, y1 := int(math.Ceil((x+w-edgeWidth)/cw))-1, int(math.Ceil((y+h-edgeWidth)/ch))-1
+	if x0 < 0 || y0 < 0 || x1 >= m.Column || y1 >= m.Row {
+		return false
+	}
+	for j := y0; j <= y1; j++ {
+		for i := x0; i <= x1; i++ {
+			if !m.capturable(i, j) {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestLoadMapLayout(t *testing.T) {
+	l, err := LoadMapLayout(config.MapConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Row != defaultMapRow || l.Column != defaultMapColumn {
+		t.Fatalf("default map is %dx%d", l.Column, l.Row)
+	}
+	if x, y := l.Spawn(ETH); x != 35 || y != 25 {
+		t.Fatalf("ETH spawns at %d,%d on the default map", x, y)
+	}
+
+	l, err = LoadMapLayout(config.MapConfig{Row: 15, Column: 21, Spawns: map[string][2]int{"BTC": {10, 7}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y := l.Spawn(BTC); x != 10 || y != 7 {
+		t.Fatalf("BTC spawns at %d,%d", x, y)
+	}
+	if x, y := l.Spawn(ETH); x != 35*21/defaultMapColumn || y != 25*15/defaultMapRow {
+		t.Fatalf("ETH default spawn is not scaled, got %d,%d", x, y)
+	}
+
+	for _, cfg := range []config.MapConfig{
+		{Spawns: map[string][2]int{"BTC": {0, 5}}},
+		{Spawns: map[string][2]int{"BTC": {5, 29}}},
+		{Spawns: map[string][2]int{"DOGE": {5, 5}}},
+		{Spawns: map[string][2]int{"BTC": {5, 5}, "ETH": {6, 6}}},
+		{Row: 300, Column: 300},
+	} {
+		if _, err := LoadMapLayout(cfg); err == nil {
+			t.Fatalf("%+v should be rejected", cfg)
+		}
+	}
+}
+
+func TestOddMapSize(t *testing.T) {
+	g := newTestGame(&config.Config{Map: config.MapConfig{Row: 15, Column: 21}})
+	g.AddPlayer(1, BTC)
+	g.GameStatus = GameRunning
+	for i := 0; i < 100; i++ {
+		g.Update()
+	}
+	if len(g.Map.Cells) != 15*21 || g.Map.Size() != (15*21+1)/2 || len(g.Map.Serialize()) != int(g.Map.Size()) {
+		t.Fatalf("%d cells packed in %d bytes", len(g.Map.Cells), g.Map.Size())
+	}
+}
+
+func TestMapTiles(t *testing.T) {
+	grid := []string{
+		"..........",
+		".........#",
+		"..........",
+		"..........",
+		"+........o",
+		"..........",
+	}
+	cfg := config.MapConfig{Grid: grid, Spawns: map[string][2]int{
+		"BTC": {1, 1}, "ETH": {4, 1}, "BNB": {7, 1}, "AVAX": {2, 4}, "MATIC": {5, 4},
+	}}
+	l, err := LoadMapLayout(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Row != 6 || l.Column != 10 || l.Tile(9, 1) != TileWall || l.Tile(9, 4) != TileHole || l.Tile(0, 4) != TileBonus {
+		t.Fatalf("grid loaded as %dx%d %v", l.Column, l.Row, l.Tiles)
+	}
+	if tiles := l.SerializeTiles(); len(tiles) != 30 || tiles[4*10/2] != byte(TileBonus)<<4 {
+		t.Fatalf("tiles packed as %v", tiles)
+	}
+
+	g := newTestGame(&config.Config{Map: cfg})
+	g.AddPlayer(1, BTC)
+	g.GameStatus = GameRunning
+	for i := 0; i < 2000; i++ {
+		g.Update()
+	}
+	if g.Map.Cells[1*10+9] != Empty || g.Map.Cells[4*10+9] != Empty {
+		t.Fatal("a wall or a hole was captured")
+	}
+	g.Map.Cells[4*10] = ETH
+	g.res = nil
+	_, score := g.GetWinner()
+	if score < l.BonusValue {
+		t.Fatalf("winner score %d", score)
+	}
+
+	// a map of walls has nowhere to put an item
+	walls := NewMap(&MapLayout{Row: 3, Column: 3, CellWidth: 20, CellHeight: 20, Tiles: make([]Tile, 9)})
+	for i := range walls.Tiles {
+		walls.Tiles[i] = TileWall
+	}
+	if _, _, ok := walls.RandomSpaceXY(g.rng, 20, 20); ok {
+		t.Fatal("random cell on a wall")
+	}
+	walls.Tiles[4] = TileNormal
+	for i := 0; i < 20; i++ {
+		if x, y, ok := walls.RandomSpaceXY(g.rng, 20, 20); ok && (x != float64(21+edgeWidth) || y != float64(21+edgeWidth)) {
+			t.Fatalf("random cell at %v,%v", x, y)
+		}
+	}
+
+	for _, grid := range [][]string{{"...", ".."}, {"..x"}} {
+		if _, err := LoadMapLayout(config.MapConfig{Grid: grid}); err == nil {
+			t.Fatalf("%v should be rejected", grid)
+		}
+	}
+	cfg.Spawns["BTC"] = [2]int{8, 3}
+	cfg.Grid[3] = "........#."
+	if _, err := LoadMapLayout(cfg); err == nil {
+		t.Fatal("spawn next to a wall should be rejected")
+	}
+}
+
+func TestRandomSpaceXY(t *testing.T) {
+	// a wall one cell thick down the third column, an item covers up to 2x2 cells
+	l := &MapLayout{Row: 6, Column: 6, CellWidth: 20, CellHeight: 20, Tiles: make([]Tile, 36)}
+	for y := 0; y < l.Row; y++ {
+		l.Tiles[y*l.Column+2] = TileWall
+	}
+	m := NewMap(l)
+	size := float64(2 * itemPixelR)
+	rng := rand.New(rand.NewSource(1))
+	spawned := 0
+	for i := 0; i < 1000; i++ {
+		x, y, ok := m.RandomSpaceXY(rng, size, size)
+		if !ok {
+			continue
+		}
+		spawned++
+		if x < edgeWidth || y < edgeWidth || x+size > edgeWidth+m.W() || y+size > edgeWidth+m.H() {
+			t.Fatalf("item at %v,%v is out of the map", x, y)
+		}
+		for j := 0; j < l.Row; j++ {
+			wx, wy := l.cellIndexToSpaceXY(2, j)
+			if overlap(x, y, size, size, wx, wy, float64(l.CellWidth), float64(l.CellHeight)) {
+				t.Fatalf("item at %v,%v is over the wall", x, y)
+			}
+		}
+	}
+	if spawned == 0 {
+		t.Fatal("no item fits next to the wall")
+	}
+
+	// with a wall every other column nothing 30 pixels wide fits
+	for y := 0; y < l.Row; y++ {
+		for x := 0; x < l.Column; x += 2 {
+			l.Tiles[y*l.Column+x] = TileWall
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if x, y, ok := m.RandomSpaceXY(rng, size, size); ok {
+			t.Fatalf("item at %v,%v between the walls", x, y)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	pausedRoute  = "onGamePaused"
+	resumedRoute = "onGameResumed"
+)
+
+// GamePause is sent on onGamePaused and onGameResumed, the times are server unix milliseconds
+type GamePause struct {
+	GameRound  uint  `json:"game_round"`
+	Remaining  int64 `json:"remaining"` // milliseconds left in the round, frozen while paused
+	EndsAt     int64 `json:"ends_at"`   // 0 while paused
+	ServerTime int64 `json:"server_time"`
+}
+
+// Pause freezes the running round: the balls, the items, the effects and the timer stop until Resume
+func (g *Game) Pause() error {
+	return g.call(func() error {
+		if g.GameStatus != GameRunning {
+			return ErrNotRunning
+		}
+		g.GameStatus = GamePaused
+		g.pausedAt = time.Now()
+		// a restart while paused must not resume the round on its own
+		g.writeCheckpoint()
+		g.notifyPause(pausedRoute)
+		return nil
+	})
+}
+
+// Resume goes on with a paused round where it stopped
+func (g *Game) Resume() error {
+	return g.call(func() error {
+		if g.GameStatus != GamePaused {
+			return ErrNotRunning
+		}
+		g.GameStatus = GameRunning
+		g.writeCheckpoint()
+		if g.dbGame != nil {
+			g.dbGame.EndTime = time.Now().Add(g.remaining())
+			g.updateGame()
+		}
+		zap.L().Info("round resumed", zap.Uint("game_id", g.gameID()), zap.Duration("paused", time.Since(g.pausedAt)))
+		g.notifyPause(resumedRoute)
+		return nil
+	})
+}
+
+// remaining is the time left in the round, counted in ticks so a pause doesn't eat it
+func (g *Game) remaining() time.Duration {
+	if g.roundTicks <= g.tick {
+		return 0
+	}
+	return time.Duration(g.roundTicks-g.tick) * time.Second / time.Duration(g.tickRate())
+}
+
+func (g *Game) notifyPause(route string) {
+	if g.onPause == nil {
+		return
+	}
+	now := time.Now()
+	gp := GamePause{
+		GameRound:  g.gameID(),
+		Remaining:  g.remaining().Milliseconds(),
+		ServerTime: now.UnixMilli(),
+	}
+	if g.GameStatus == GameRunning {
+		gp.EndsAt = now.Add(g.remaining()).UnixMilli()
+	}
+	g.onPause(route, gp)
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"context"
+	"sync"
+)
+
+// jobQueue holds the jobs waiting for the job goroutine, it grows as needed so queuing never blocks the ticks
+type jobQueue struct {
+	mu    sync.Mutex
+	jobs  []func()
+	ready chan struct{}
+}
+
+// gameRow is the row of a round in the games table, its id is only known to the job goroutine once
+// the row is created
+type gameRow struct {
+	id uint
+}
+
+// persist queues f for the job goroutine, the database writes and the mints of the rounds run there in
+// order so that they never hold up the ticks. f must only use what it captured, not the live game.
+func (g *Game) persist(f func()) {
+	if g.ctx.Err() != nil {
+		return
+	}
+	g.pending.Add(1)
+	q := &g.jobs
+	q.mu.Lock()
+	q.jobs = append(q.jobs, f)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// persistRound is persist for the writes of the current round, f gets the id of its row and is skipped
+// when the row couldn't be created
+func (g *Game) persistRound(f func(id uint)) {
+	row := g.row
+	g.persist(func() {
+		if row != nil && row.id != 0 {
+			f(row.id)
+		}
+	})
+}
+
+func (g *Game) runJobs() {
+	q := &g.jobs
+	for {
+		select {
+		case <-q.ready:
+		case <-g.ctx.Done():
+			return
+		}
+		for {
+			q.mu.Lock()
+			if len(q.jobs) == 0 {
+				q.mu.Unlock()
+				break
+			}
+			f := q.jobs[0]
+			q.jobs[0] = nil
+			q.jobs = q.jobs[1:]
+			q.mu.Unlock()
+			f()
+			g.pending.Done()
+		}
+	}
+}
+
+// waitJobs returns when the queued jobs are done or ctx is
+func (g *Game) waitJobs(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
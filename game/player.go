@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"math"
-	"math/rand"
 
 	"github.com/solarlune/resolv"
 )
@@ -12,6 +11,10 @@ import (
 const (
 	PlayerTag           = "Player"
 	defaultPlayerPixelR = 5
+
+	playerRecordVersion = 1
+	playerRecordSize    = 49 // without the effects
+	effectRecordSize    = 5
 )
 
 type Player struct {
@@ -24,18 +27,30 @@ type Player struct {
 	Vx float64 `json:"vx"`
 	Vy float64 `json:"vy"`
 
+	effects   []Effect
+	owner     uint64 // the player a split ball was split from
+	spawnTick uint32
 	playerObj *resolv.Object
 }
 
 /*Serialize
+version 1 byte
 ID 8 byte
+camp 1 byte
 R 2 byte
 X 8 byte
 Y 8 byte
+Vx 8 byte, pixels per tick
+Vy 8 byte
+spawn tick 4 byte
+effect number 1 byte
+effects 5 * effect number byte (type 1 byte, until tick 4 byte, 0 until used up)
 */
 func (p *Player) Serialize() []byte {
-	bytesBuffer := bytes.NewBuffer(make([]byte, 0))
+	bytesBuffer := bytes.NewBuffer(make([]byte, 0, p.Size()))
+	binary.Write(bytesBuffer, binary.BigEndian, uint8(playerRecordVersion))
 	binary.Write(bytesBuffer, binary.BigEndian, p.ID)
+	binary.Write(bytesBuffer, binary.BigEndian, uint8(p.Camp))
 	binary.Write(bytesBuffer, binary.BigEndian, uint16(p.R))
 	x, y := float64(0), float64(0)
 	if p.playerObj != nil {
@@ -43,11 +58,19 @@ func (p *Player) Serialize() []byte {
 	}
 	binary.Write(bytesBuffer, binary.BigEndian, x)
 	binary.Write(bytesBuffer, binary.BigEndian, y)
+	binary.Write(bytesBuffer, binary.BigEndian, p.Vx)
+	binary.Write(bytesBuffer, binary.BigEndian, p.Vy)
+	binary.Write(bytesBuffer, binary.BigEndian, p.spawnTick)
+	binary.Write(bytesBuffer, binary.BigEndian, uint8(len(p.effects)))
+	for _, e := range p.effects {
+		binary.Write(bytesBuffer, binary.BigEndian, uint8(e.Type))
+		binary.Write(bytesBuffer, binary.BigEndian, e.Until)
+	}
 	return bytesBuffer.Bytes()
 }
 
 func (p *Player) Size() uint32 {
-	return 18
+	return playerRecordSize + effectRecordSize*uint32(len(p.effects))
 }
 
 func (p *Player) GetCenter() (float64, float64) {
@@ -102,21 +125,64 @@ func (g *Game) AddPlayer(playerID uint64, camp Camp) *Player {
 	if camp == Empty {
 		return nil
 	}
-	g.incrCampVotes(camp)
-	x, y := cellIndexToSpaceXY(camp.CenterCellIndex(mapRow, mapColumn))
+	// bots aren't votes, they neither break ties nor show in the camp votes
+	if !IsBotID(playerID) {
+		g.incrCampVotes(camp)
+	}
+	x, y := g.layout.cellIndexToSpaceXY(g.layout.Spawn(camp))
 
-	ang := rand.Float64() * 2 * math.Pi
+	ang := g.rng.Float64() * 2 * math.Pi
+	speed := g.perTick(playerInitialVelocity)
+	player := g.addBall(playerID, camp, x, y, math.Cos(ang)*speed, math.Sin(ang)*speed)
+	g.replay.recordVote(g.tick, playerID, camp)
+
+	//fmt.Println("new player, camp:", camp, "x:", player.playerObj.X, "y:", player.playerObj.Y, "vx:", player.Vx, "vy:", player.Vy)
+	return player
+}
+
+func (g *Game) addBall(id uint64, camp Camp, x, y, vx, vy float64) *Player {
 	player := &Player{
-		ID:   playerID,
+		ID:   id,
 		Camp: camp,
 		R:    defaultPlayerPixelR,
-		Vx:   math.Cos(ang) * playerInitialVelocity,
-		Vy:   math.Sin(ang) * playerInitialVelocity,
+		Vx:   vx,
+		Vy:   vy,
+
+		spawnTick: g.tick,
 	}
 	player.playerObj = resolv.NewObject(x, y, float64(2*player.R), float64(2*player.R), PlayerTag)
 	g.space.Add(player.playerObj)
-	g.Players.Store(playerID, player)
-
-	//fmt.Println("new player, camp:", camp, "x:", player.playerObj.X, "y:", player.playerObj.Y, "vx:", player.Vx, "vy:", player.Vy)
+	g.Players.Store(id, player)
 	return player
 }
+
+type vote struct {
+	playerID uint64
+	camp     Camp
+}
+
+// Vote queues a player for the next tick, votes are applied between two updates
+// so that the tick recorded in the replay is the one the ball really joined at.
+func (g *Game) Vote(playerID uint64, camp Camp) {
+	if camp == Empty {
+		return
+	}
+	select {
+	case g.votes <- vote{playerID: playerID, camp: camp}:
+	case <-g.ctx.Done():
+	}
+}
+
+// applyVotes adds the queued players, the votes are dropped once the game is closing
+func (g *Game) applyVotes() {
+	for {
+		select {
+		case v := <-g.votes:
+			if !g.closing {
+				g.AddPlayer(v.playerID, v.camp)
+			}
+		default:
+			return
+		}
+	}
+}
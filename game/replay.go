@@ -0,0 +1,224 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/db"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"go.uber.org/zap"
+)
+
+const (
+	replayVersion  = 1
+	maxReplaySpeed = 8
+)
+
+var ErrBadReplay = errors.New("bad replay data")
+
+type ReplayVote struct {
+	Tick     uint32
+	PlayerID uint64
+	Camp     Camp
+}
+
+type ReplayItem struct {
+	Tick uint32
+	Id   uint32
+	Type ItemType
+	X    float64
+	Y    float64
+}
+
+// Replay holds the inputs of a round, replaying the votes on top of the seed gives back the same round
+type Replay struct {
+	Seed   int64
+	Ticks  uint32
+	Votes  []ReplayVote
+	Items  []ReplayItem
+	Config *config.Config // nil when the replay was saved without it
+}
+
+func newReplay(seed int64) *Replay {
+	return &Replay{Seed: seed}
+}
+
+func (r *Replay) recordVote(tick uint32, playerID uint64, camp Camp) {
+	r.Votes = append(r.Votes, ReplayVote{Tick: tick, PlayerID: playerID, Camp: camp})
+}
+
+func (r *Replay) recordItem(tick uint32, item *ItemObject) {
+	r.Items = append(r.Items, ReplayItem{Tick: tick, Id: item.Id, Type: item.Item.Type, X: item.X, Y: item.Y})
+}
+
+/*
+version: 1 byte
+vote number: 4 bytes
+votes: 13 * vote number bytes (tick 4 bytes, player id 8 bytes, camp 1 byte)
+item number: 4 bytes
+items: 25 * item number bytes (tick 4 bytes, id 4 bytes, type 1 byte, x 8 bytes, y 8 bytes)
+*/
+func (r *Replay) Serialize() []byte {
+	bytesBuffer := bytes.NewBuffer(make([]byte, 0))
+	binary.Write(bytesBuffer, binary.BigEndian, uint8(replayVersion))
+	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(r.Votes)))
+	for _, v := range r.Votes {
+		binary.Write(bytesBuffer, binary.BigEndian, v.Tick)
+		binary.Write(bytesBuffer, binary.BigEndian, v.PlayerID)
+		binary.Write(bytesBuffer, binary.BigEndian, uint8(v.Camp))
+	}
+	binary.Write(bytesBuffer, binary.BigEndian, uint32(len(r.Items)))
+	for _, i := range r.Items {
+		binary.Write(bytesBuffer, binary.BigEndian, i.Tick)
+		binary.Write(bytesBuffer, binary.BigEndian, i.Id)
+		binary.Write(bytesBuffer, binary.BigEndian, uint8(i.Type))
+		binary.Write(bytesBuffer, binary.BigEndian, i.X)
+		binary.Write(bytesBuffer, binary.BigEndian, i.Y)
+	}
+	return bytesBuffer.Bytes()
+}
+
+func DecodeReplay(m *model.Replay) (*Replay, error) {
+	r := &Replay{Seed: m.Seed, Ticks: m.Ticks}
+	if len(m.Config) > 0 {
+		r.Config = &config.Config{}
+		if err := json.Unmarshal(m.Config, r.Config); err != nil {
+			return nil, ErrBadReplay
+		}
+	}
+	buf := bytes.NewReader(m.Data)
+	var version uint8
+	if err := binary.Read(buf, binary.BigEndian, &version); err != nil || version != replayVersion {
+		return nil, ErrBadReplay
+	}
+	var n uint32
+	if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+		return nil, ErrBadReplay
+	}
+	for i := uint32(0); i < n; i++ {
+		var v struct {
+			Tick     uint32
+			PlayerID uint64
+			Camp     uint8
+		}
+		if err := binary.Read(buf, binary.BigEndian, &v); err != nil {
+			return nil, ErrBadReplay
+		}
+		r.Votes = append(r.Votes, ReplayVote{Tick: v.Tick, PlayerID: v.PlayerID, Camp: Camp(v.Camp)})
+	}
+	if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+		return nil, ErrBadReplay
+	}
+	for i := uint32(0); i < n; i++ {
+		var v struct {
+			Tick uint32
+			Id   uint32
+			Type uint8
+			X    float64
+			Y    float64
+		}
+		if err := binary.Read(buf, binary.BigEndian, &v); err != nil {
+			return nil, ErrBadReplay
+		}
+		r.Items = append(r.Items, ReplayItem{Tick: v.Tick, Id: v.Id, Type: ItemType(v.Type), X: v.X, Y: v.Y})
+	}
+	return r, nil
+}
+
+// replayRecord is the replay row of the round, nil when nothing was recorded
+func (g *Game) replayRecord() *model.Replay {
+	if g.replay == nil {
+		return nil
+	}
+	g.replay.Ticks = g.tick
+	cfg, err := json.Marshal(g.replayConfig())
+	if err != nil {
+		zap.L().Error("failed to encode replay config", zap.Uint("game_id", g.dbGame.ID), zap.Error(err))
+	}
+	return &model.Replay{
+		GameID:  g.dbGame.ID,
+		ArenaID: g.arenaID,
+		Seed:    g.replay.Seed,
+		Ticks:   g.replay.Ticks,
+		Data:    g.replay.Serialize(),
+		Config:  cfg,
+	}
+}
+
+// replayConfig is the config of the round without the secrets, the map is inlined so that editing
+// the map file doesn't change the replay
+func (g *Game) replayConfig() *config.Config {
+	cfg := *g.cfg
+	cfg.Database = db.Config{}
+	cfg.Seed = ""
+	cfg.AdminTokens = nil
+	cfg.Arenas = nil
+	cfg.GameDuration = g.duration
+	cfg.Map = g.layout.mapConfig()
+	return &cfg
+}
+
+// Play simulates the recorded round again with the config it was played with and calls onFrame with a
+// keyframe at the broadcast rate, speed > 1 plays it faster. It returns when the round is over or ctx is done.
+func (r *Replay) Play(ctx context.Context, speed float64, onFrame func([]byte)) {
+	cfg := r.Config
+	if cfg == nil {
+		// the defaults, the caller should have set the config of the arena
+		cfg = &config.Config{}
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	if speed > maxReplaySpeed {
+		speed = maxReplaySpeed
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g := NewGame(ctx, cfg, nil, nil, func(context.Context) {}, func(context.Context, GameStop) {}, func(Camp, int32) {})
+	g.seedRound(r.Seed)
+	g.GameStatus = GameRunning
+
+	fps := cfg.FPS
+	if fps <= 0 {
+		fps = defaultTickRate
+	}
+	ticksPerFrame := uint32(g.tickRate() / fps)
+	if ticksPerFrame == 0 {
+		ticksPerFrame = 1
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / (float64(g.tickRate()) * speed)))
+	defer ticker.Stop()
+	votes, items := r.Votes, r.Items
+	// sub-rounds are cut from the full round even when it ended early
+	g.roundTicks = uint32(cfg.GameDuration * g.tickRate())
+	if g.roundTicks < r.Ticks {
+		g.roundTicks = r.Ticks
+	}
+	for over := false; !over && g.tick < r.Ticks; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for len(votes) > 0 && votes[0].Tick <= g.tick {
+			g.AddPlayer(votes[0].PlayerID, votes[0].Camp)
+			votes = votes[1:]
+		}
+		over = g.advance()
+		for len(items) > 0 && items[0].Tick < g.tick {
+			if _, ok := g.Items.Load(items[0].Id); !ok {
+				zap.L().Warn("replay diverged, recorded item not spawned", zap.Uint32("item_id", items[0].Id), zap.Uint32("tick", items[0].Tick))
+			}
+			items = items[1:]
+		}
+		if g.tick%ticksPerFrame == 0 || g.tick == r.Ticks || over {
+			s, _ := g.Serialize()
+			onFrame(s)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+func TestReplayPlay(t *testing.T) {
+	cfg := &config.Config{FPS: 100000, ItemFrameChance: 20}
+	g := newTestGame(cfg)
+	g.seedRound(7)
+	g.GameStatus = GameRunning
+	for i := 0; i < 600; i++ {
+		switch i {
+		case 0:
+			g.AddPlayer(1, BTC)
+			g.AddPlayer(2, ETH)
+		case 100:
+			g.AddPlayer(3, AVAX)
+		}
+		g.Update()
+	}
+	g.replay.Ticks = g.tick
+
+	recorded, err := json.Marshal(g.replayConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the arena config changing after the round doesn't change the replay
+	cfg.ItemFrameChance = 500
+	cfg.Map.Row = 20
+	replay, err := DecodeReplay(&model.Replay{Seed: g.replay.Seed, Ticks: g.replay.Ticks, Data: g.replay.Serialize(), Config: recorded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Votes) != 3 || len(replay.Items) != len(g.replay.Items) {
+		t.Fatalf("decoded %d votes %d items, want 3 votes %d items", len(replay.Votes), len(replay.Items), len(g.replay.Items))
+	}
+
+	var last []byte
+	frames := 0
+	replay.Play(context.Background(), maxReplaySpeed, func(frame []byte) {
+		frames++
+		last = frame
+	})
+	if frames != 600 {
+		t.Fatalf("got %d frames, want 600", frames)
+	}
+	if !equalCells(decodeKeyFrame(t, last).cells, g.Map.Cells) {
+		t.Fatal("replayed cells differ from the recorded round")
+	}
+
+	// without a frame rate it plays a frame every tick of the default rate
+	replay.Config.FPS, replay.Ticks, frames = 0, 10, 0
+	replay.Play(context.Background(), maxReplaySpeed, func([]byte) { frames++ })
+	if frames != 10 {
+		t.Fatalf("got %d frames without a frame rate, want 10", frames)
+	}
+}
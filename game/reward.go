@@ -0,0 +1,45 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+	"github.com/ZecreyGaming/BlockChainWar/db"
+	"github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
+	"github.com/ZecreyGaming/BlockChainWar/model"
+	"go.uber.org/zap"
+)
+
+// rewardRound queues the mint of the NFT of the round for the player who started it
+func (g *Game) rewardRound() {
+	if g.sdkClient == nil || g.db == nil || g.toRewardName == "" {
+		return
+	}
+	r := &model.Reward{GameID: g.gameID(), ArenaID: g.arenaID, AccountName: g.toRewardName, Status: model.RewardPending}
+	g.persistRound(func(id uint) {
+		r.GameID = id
+		if err := g.db.Reward.Create(r); err != nil {
+			zap.L().Error("failed to create reward", zap.Error(err))
+		}
+		MintReward(g.db, g.sdkClient, g.cfg, r)
+	})
+}
+
+// MintReward mints the NFT of the reward and stores the outcome, failed rewards can be minted again
+func MintReward(d *db.Client, sdkClient *zecreyface.Client, cfg *config.Config, r *model.Reward) error {
+	r.Attempts++
+	_, err := sdkClient.MintNft(cfg.CollectionId, r.AccountName,
+		fmt.Sprintf("%s%d", cfg.NftPrefix, time.Now().UnixMilli()),
+		fmt.Sprintf("zecrey MintNft %d", time.Now().UnixMilli()))
+	if err != nil {
+		zap.L().Error("MintNft", zap.Uint("game_id", r.GameID), zap.String("account", r.AccountName), zap.Error(err))
+		r.Status, r.Error = model.RewardFailed, err.Error()
+	} else {
+		r.Status, r.Error = model.RewardMinted, ""
+	}
+	if err := d.Reward.Update(r); err != nil {
+		zap.L().Error("failed to update reward", zap.Uint("reward_id", r.ID), zap.Error(err))
+	}
+	return err
+}
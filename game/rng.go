@@ -0,0 +1,36 @@
+package game
+
+import "math/rand"
+
+// countingSource counts the numbers drawn from the seeded source, the seed and the count are
+// enough to bring the source back to the same state after a restart
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.draws = 0
+}
+
+// skip draws n numbers, Int63 and Uint64 both move the source by one step
+func (s *countingSource) skip(n uint64) {
+	for ; n > 0; n-- {
+		s.Uint64()
+	}
+}
@@ -2,12 +2,13 @@ package game
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
 	"go.uber.org/zap"
 
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ZecreyGaming/BlockChainWar/config"
 	"github.com/ZecreyGaming/BlockChainWar/db"
@@ -25,65 +26,67 @@ type Room struct {
 	db  *db.Client
 
 	tickerCancel context.CancelFunc
-	game         *Game
+	arenas       *Arenas
 }
 
 type GameUpdate struct {
 	Data []byte `json:"data"`
 }
 
-func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config, sdkClient *zecreyface.Client) *Game {
-	err := app.GroupCreate(context.Background(), config.GameRoomName)
-	if err != nil {
-		panic(err)
-	}
+func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config, sdkClient *zecreyface.Client) *Arenas {
 	r := &Room{
 		app: app,
 		db:  db,
 		cfg: cfg,
 	}
+	if len(cfg.Camps) > 0 {
+		if err := SetCamps(cfg.Camps); err != nil {
+			panic(err)
+		}
+	}
+	if err := db.Camp.Sync(CampModels()); err != nil {
+		panic(err)
+	}
 	r.ctx, r.tickerCancel = context.WithCancel(context.Background())
-	r.game = NewGame(r.ctx, cfg, db, sdkClient, r.onGameStart, r.onGameStop, r.onCampVotesChange)
+	arenas, err := newArenas(r.ctx, app, db, cfg, sdkClient)
+	if err != nil {
+		panic(err)
+	}
+	r.arenas = arenas
 	app.Register(r,
 		component.WithName(config.GameRoomName),
 		component.WithNameFunc(strings.ToLower),
 	)
-	return r.game
+	return r.arenas
 }
 
 func (r *Room) AfterInit() {
-	stateChan := r.game.start()
-	go func() {
-		// ticker := time.Tick(time.Duration(33) * time.Millisecond)
-		ticker := time.NewTicker(time.Duration(1000/r.cfg.FPS) * time.Millisecond).C
-		for {
-			select {
-			//case nextRoundChan := <-r.game.stopSignalChan:
-			//	<-nextRoundChan
-			case <-r.ctx.Done():
-				return
-			default:
-				s := <-stateChan
-				<-ticker
-				err := r.app.GroupBroadcast(context.Background(), r.cfg.FrontendType, config.GameRoomName, "onUpdate", GameUpdate{Data: s})
-				if err != nil {
-					zap.L().Error("broadcast onUpdate failed", zap.Error(err))
-				}
-			}
-		}
-	}()
+	for _, a := range r.arenas.List() {
+		a.run()
+	}
 }
 
+// Shutdown finalizes the rounds before stopping the games. main and the admin api already did it
+// while the clients were connected, this only catches the other ways pitaya stops.
 func (r *Room) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout(r.cfg.Shutdown.Timeout))
+	defer cancel()
+	r.arenas.Shutdown(ctx)
 	r.tickerCancel()
 }
 
+// JoinRequest picks the arena to play in, the default arena is used when it's empty
+type JoinRequest struct {
+	ArenaID string `json:"arena_id"`
+}
+
 // JoinResponse represents the result of joining room
 type JoinResponse struct {
 	Code       int    `json:"code"`
 	Result     string `json:"result"`
 	GameStatus uint8  `json:"game_status"`
 	Winner     uint8  `json:"winner"`
+	ArenaID    string `json:"arena_id"`
 }
 
 // NewUser message will be received when new user join room
@@ -91,6 +94,19 @@ type NewUser struct {
 	Content string `json:"content"`
 }
 
+type ArenaList struct {
+	Arenas []ArenaInfo `json:"arenas"`
+}
+
+// List the arenas running on this server
+func (r *Room) List(ctx context.Context, msg []byte) (*ArenaList, error) {
+	l := &ArenaList{}
+	for _, a := range r.arenas.List() {
+		l.Arenas = append(l.Arenas, a.Info())
+	}
+	return l, nil
+}
+
 // Join room
 func (r *Room) Join(ctx context.Context, msg []byte) (*JoinResponse, error) {
 	// if r.game == nil || r.game.GameStatus != GameRunning {
@@ -105,6 +121,29 @@ func (r *Room) Join(ctx context.Context, msg []byte) (*JoinResponse, error) {
 		return nil, pitaya.Error(err, "RH-000", map[string]string{"failed": "bind"})
 	}
 
+	// the request body is optional, old clients join the default arena
+	var req JoinRequest
+	if len(msg) > 0 {
+		json.Unmarshal(msg, &req)
+	}
+	prev := r.arenas.FromSession(s)
+	a := prev
+	if req.ArenaID != "" {
+		var ok bool
+		if a, ok = r.arenas.Get(req.ArenaID); !ok {
+			return nil, pitaya.Error(fmt.Errorf("arena %s not found", req.ArenaID), "RH-404", map[string]string{"failed": "arena not found"})
+		}
+	}
+	// switching arena moves the session out of the groups of the previous one
+	if prev != a && s.HasKey(arenaSessionKey) {
+		r.app.GroupRemoveMember(ctx, prev.gameGroup, s.UID())
+		if in, _ := r.app.GroupContainsMember(ctx, prev.chatGroup, s.UID()); in {
+			r.app.GroupRemoveMember(ctx, prev.chatGroup, s.UID())
+			r.app.GroupAddMember(ctx, a.chatGroup, s.UID())
+		}
+	}
+	s.Set(arenaSessionKey, a.ID)
+
 	// uids, err := r.app.GroupMembers(ctx, config.GameRoomName)
 	// if err != nil {
 	// 	return nil, err
@@ -112,75 +151,102 @@ func (r *Room) Join(ctx context.Context, msg []byte) (*JoinResponse, error) {
 	// s.Push("onMembers", &AllMembers{Members: uids})
 
 	// new user join group
-	r.app.GroupAddMember(ctx, config.GameRoomName, s.UID()) // add session to group
+	r.app.GroupAddMember(ctx, a.gameGroup, s.UID()) // add session to group
+	// deltas are useless without a base frame
+	a.game.frames.requestKeyFrame()
 	//todo 发游戏状态
 	// notify others
-	r.onJoin(ctx, false)
+	a.onJoin(ctx, false)
 
 	// on session close, remove it from group
 	s.OnClose(func() {
-		r.app.GroupRemoveMember(ctx, config.GameRoomName, s.UID())
+		r.app.GroupRemoveMember(ctx, r.arenas.FromSession(s).gameGroup, s.UID())
 	})
-	gameInfo, err := r.game.GetGameInfo()
+	gameInfo, err := a.game.GetGameInfo()
 	//fmt.Println("gameInfo === ", JoinResponse{Result: "success", Code: 0, GameStatus: uint8(gameInfo.GameStatus), Winner: gameInfo.WinnerId})
-	return &JoinResponse{Result: "success", Code: 0, GameStatus: uint8(gameInfo.GameStatus), Winner: gameInfo.WinnerId}, nil //code == 0 join game
+	return &JoinResponse{Result: "success", Code: 0, GameStatus: uint8(gameInfo.GameStatus), Winner: gameInfo.WinnerId, ArenaID: a.ID}, nil //code == 0 join game
+}
+
+// Keyframe asks for a full frame, clients call it when they miss a delta
+func (r *Room) Keyframe(ctx context.Context, msg []byte) (*JoinResponse, error) {
+	r.arenas.FromSession(r.app.GetSessionFromCtx(ctx)).game.frames.requestKeyFrame()
+	return &JoinResponse{Result: "success", Code: 0}, nil
 }
 
-// onJoin room
-func (r *Room) onJoin(ctx context.Context, replay bool) {
-	mi := MapInfo{
-		Row:        mapRow,
-		Column:     mapColumn,
-		CellWidth:  cellWidth,
-		CellHeight: cellHeight,
+type ReplayRequest struct {
+	GameID uint    `json:"game_id"`
+	Speed  float64 `json:"speed"`
+}
 
-		Item:   AllItems,
-		Replay: replay,
+// Replay streams a finished round to the requesting session over onUpdate, the session
+// leaves the live game group until the replay is over.
+func (r *Room) Replay(ctx context.Context, req *ReplayRequest) (*JoinResponse, error) {
+	s := r.app.GetSessionFromCtx(ctx)
+	if s.UID() == "" {
+		return nil, pitaya.Error(constants.ErrNoUIDBind, "RH-400", map[string]string{"failed": "join the game first"})
+	}
+	m, err := r.db.Replay.GetByGameID(req.GameID)
+	if err != nil {
+		return nil, pitaya.Error(err, "RH-404", map[string]string{"failed": "replay not found"})
+	}
+	replay, err := DecodeReplay(m)
+	if err != nil {
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "decode replay"})
+	}
+	if replay.Config == nil {
+		// saved without its config, the arena it was played in is the closest
+		recorded, ok := r.arenas.Get(m.ArenaID)
+		if !ok {
+			recorded = r.arenas.Default()
+		}
+		replay.Config = recorded.cfg
+	}
+	layout, err := LoadMapLayout(replay.Config.Map)
+	if err != nil {
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "load replay map"})
 	}
 
-	pids := []uint64{}
-	r.game.Players.Range(func(key, value interface{}) bool {
-		pids = append(pids, key.(uint64))
-		return true
+	a := r.arenas.FromSession(s)
+	uid := s.UID()
+	r.app.GroupRemoveMember(ctx, a.gameGroup, uid)
+	playCtx, cancel := context.WithCancel(r.ctx)
+	closed := make(chan struct{})
+	s.OnClose(func() {
+		close(closed)
+		cancel()
 	})
-
-	mi.Players, _ = r.db.Player.List(pids...)
-	r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onJoin", mi)
-}
-
-func (r *Room) onGameStart(ctx context.Context) {
-	info, _ := r.game.GetGameInfo()
-	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.GameRoomName, "onGameStart", info)
-	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "onGameStart", info)
-	r.onJoin(ctx, true)
+	s.Push("onJoin", layoutInfo(layout, true))
+	go func() {
+		defer cancel()
+		replay.Play(playCtx, req.Speed, func(frame []byte) {
+			if err := s.Push("onUpdate", GameUpdate{Data: frame}); err != nil {
+				zap.L().Error("push replay frame failed", zap.Uint("game_id", req.GameID), zap.Error(err))
+				cancel()
+			}
+		})
+		select {
+		case <-closed:
+			return
+		case <-r.ctx.Done():
+			return
+		default:
+		}
+		s.Push("onReplayEnd", ReplayEnd{GameID: req.GameID})
+		r.app.GroupAddMember(context.Background(), a.gameGroup, uid)
+		a.game.frames.requestKeyFrame()
+	}()
+	return &JoinResponse{Result: "success", Code: 0}, nil
 }
 
 // getGameInfo room
 func (r *Room) getGameInfo(ctx context.Context, msg []byte) (*JoinResponse, error) {
-	info, _ := r.game.GetGameInfo()
-	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "getGameInfo", info)
-	r.onJoin(ctx, true)
+	a := r.arenas.FromSession(r.app.GetSessionFromCtx(ctx))
+	info, _ := a.game.GetGameInfo()
+	r.app.GroupBroadcast(r.ctx, a.cfg.FrontendType, a.chatGroup, "getGameInfo", info)
+	a.onJoin(ctx, true)
 	return &JoinResponse{Result: "success", Code: 0}, nil //code == 0 join game
 }
 
-func (r *Room) onUpdate(s []byte) {
-	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.GameRoomName, "onUpdate", GameUpdate{Data: s})
-}
-
-func (r *Room) onGameStop(ctx context.Context) {
-	stop := r.game.GetGameStop()
-	//fmt.Println("winner info ", stop)
-	r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onGameStop", stop)
-	r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.ChatRoomName, "onGameStop", stop)
-}
-
-func (r *Room) onCampVotesChange(camp Camp, votes int32) {
-	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "onCampVotesChange", CampVotesChange{
-		Camp:  camp,
-		Votes: votes,
-	})
-}
-
 // TODO
 type MapInfo struct {
 	Row    uint32 `json:"row"`
@@ -189,11 +255,19 @@ type MapInfo struct {
 	CellWidth  uint32 `json:"cell_width"`
 	CellHeight uint32 `json:"cell_height"`
 
+	Tiles      []byte `json:"tiles"` // 4 bits per cell, packed like the cells of a frame: 0 normal, 1 wall, 2 hole, 3 bonus
+	BonusValue uint32 `json:"bonus_value"`
+
+	Camps   []CampInfo     `json:"camps"`
 	Item    []Item         `json:"items"`
 	Players []model.Player `json:"players"`
 	Replay  bool           `json:"replay"`
 }
 
+type ReplayEnd struct {
+	GameID uint `json:"game_id"`
+}
+
 type CampVotesChange struct {
 	Camp  Camp  `json:"camp"`
 	Votes int32 `json:"votes"`
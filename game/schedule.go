@@ -0,0 +1,145 @@
+package game
+
+import (
+	"time"
+)
+
+type Phase string
+
+const (
+	PhaseLobby   Phase = "lobby"   // votes are taken, the balls wait at their spawn
+	PhaseRunning Phase = "running" // the round is played
+	PhaseResults Phase = "results" // the final map stays on screen
+
+	phaseRoute = "onPhaseChange"
+)
+
+// PhaseChange is broadcast when the scheduler moves to another phase, the times are server unix milliseconds
+type PhaseChange struct {
+	Phase      Phase `json:"phase"`
+	GameRound  uint  `json:"game_round"`
+	StartedAt  int64 `json:"started_at"`
+	EndsAt     int64 `json:"ends_at"` // 0 while the lobby waits for players
+	ServerTime int64 `json:"server_time"`
+	Players    int   `json:"players"`
+	MinPlayers int   `json:"min_players"`
+}
+
+func (g *Game) scheduled() bool {
+	return g.cfg.Schedule.Enabled
+}
+
+func (g *Game) lobbyTicks() uint32 {
+	return g.secondsToTicks(g.cfg.Schedule.Lobby)
+}
+
+func (g *Game) resultsTicks() uint32 {
+	if g.cfg.Schedule.Results > 0 {
+		return g.secondsToTicks(g.cfg.Schedule.Results)
+	}
+	return g.secondsToTicks(g.cfg.GameRoundInterval)
+}
+
+// AcceptsVotes is false while the results of a scheduled round are shown
+func (g *Game) AcceptsVotes() bool {
+	return g.Snapshot().AcceptsVotes
+}
+
+// schedule moves the scheduled rounds from a phase to the next, it's called every tick
+func (g *Game) schedule() {
+	if !g.scheduled() {
+		return
+	}
+	g.phaseTicks++
+	switch g.phase {
+	case PhaseLobby:
+		if g.playerCount() < g.cfg.Schedule.MinPlayers {
+			// the countdown starts over once there are enough players
+			g.phaseTicks = 0
+			return
+		}
+		if g.phaseTicks == 1 && g.cfg.Schedule.MinPlayers > 0 {
+			g.notifyPhase()
+		}
+		if g.phaseTicks >= g.lobbyTicks() {
+			g.runRound()
+		}
+	case PhaseResults:
+		if g.phaseTicks >= g.resultsTicks() {
+			g.enterLobby()
+		}
+	}
+}
+
+// enterLobby resets the game for the next round, the votes of the lobby play in it
+func (g *Game) enterLobby() {
+	g.Reset()
+	g.seedRound(g.newRoundSeed())
+	g.toRewardName = ""
+	g.initGameInfo()
+	g.setPhase(PhaseLobby)
+}
+
+func (g *Game) runRound() {
+	g.GameStatus = GameRunning
+	g.roundTicks = uint32(g.duration * g.tickRate())
+	g.dbGame.StartTime = time.Now()
+	g.dbGame.EndTime = g.dbGame.StartTime.Add(time.Duration(g.duration) * time.Second)
+	g.updateGame()
+	g.setPhase(PhaseRunning)
+	g.publish()
+	g.onGameStart(g.ctx)
+}
+
+func (g *Game) setPhase(p Phase) {
+	g.phase = p
+	g.phaseTicks = 0
+	g.phaseStartedAt = time.Now()
+	g.notifyPhase()
+}
+
+func (g *Game) notifyPhase() {
+	if g.onPhaseChange != nil {
+		g.onPhaseChange(g.PhaseInfo())
+	}
+}
+
+// PhaseInfo is the current phase of the scheduler, Phase is empty when rounds aren't scheduled
+func (g *Game) PhaseInfo() PhaseChange {
+	now := time.Now()
+	pc := PhaseChange{
+		Phase:      g.phase,
+		GameRound:  g.gameID(),
+		StartedAt:  g.phaseStartedAt.UnixMilli(),
+		ServerTime: now.UnixMilli(),
+		Players:    g.playerCount(),
+		MinPlayers: g.cfg.Schedule.MinPlayers,
+	}
+	var left uint32
+	switch g.phase {
+	case PhaseLobby:
+		if pc.Players < pc.MinPlayers {
+			return pc
+		}
+		left = g.lobbyTicks() - g.phaseTicks
+	case PhaseRunning:
+		left = g.roundTicks - g.tick
+	case PhaseResults:
+		left = g.resultsTicks() - g.phaseTicks
+	default:
+		return pc
+	}
+	pc.EndsAt = now.Add(time.Duration(left) * time.Second / time.Duration(g.tickRate())).UnixMilli()
+	return pc
+}
+
+func (g *Game) playerCount() int {
+	n := 0
+	g.Players.Range(func(key, value interface{}) bool {
+		if p, ok := value.(*Player); ok && p.ID < splitBallIDBase {
+			n++
+		}
+		return true
+	})
+	return n
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestSchedule(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 10, GameDuration: 2, Schedule: config.ScheduleConfig{Enabled: true, Lobby: 1, Results: 1, MinPlayers: 1}})
+	var phases []PhaseChange
+	g.onPhaseChange = func(pc PhaseChange) { phases = append(phases, pc) }
+	g.Reset()
+	g.enterLobby()
+
+	for i := 0; i < 50; i++ {
+		g.step()
+	}
+	if g.phase != PhaseLobby || len(phases) != 1 || phases[0].EndsAt != 0 {
+		t.Fatalf("lobby didn't wait for players: %+v", phases)
+	}
+
+	g.Vote(1, BTC)
+	// lobby, round and results
+	for i := 0; i < 10+20+10+1; i++ {
+		g.step()
+	}
+	want := []Phase{PhaseLobby, PhaseLobby, PhaseRunning, PhaseResults, PhaseLobby}
+	if len(phases) != len(want) {
+		t.Fatalf("phases %+v", phases)
+	}
+	for i, p := range want {
+		if phases[i].Phase != p {
+			t.Fatalf("phase %d is %s, want %s", i, phases[i].Phase, p)
+		}
+	}
+	if phases[1].EndsAt <= phases[1].StartedAt || phases[2].EndsAt-phases[2].ServerTime < 1900 {
+		t.Fatalf("countdowns %+v", phases[1:3])
+	}
+	if g.GameStatus != GameNotStarted || g.playerCount() != 0 {
+		t.Fatal("the next lobby didn't reset the game")
+	}
+}
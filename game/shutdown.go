@@ -0,0 +1,119 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ShutdownFinish = "finish" // the round ends now, scored and rewarded like a round that ran out of time
+	ShutdownVoid   = "void"   // the round is voided, nobody scores
+	ShutdownResume = "resume" // the round is checkpointed and goes on after the restart
+
+	shutdownRoute = "onServerShutdown"
+
+	defaultShutdownTimeout = 10 // seconds
+)
+
+func validShutdownPolicy(policy string) error {
+	switch policy {
+	case "", ShutdownFinish, ShutdownVoid, ShutdownResume:
+		return nil
+	}
+	return fmt.Errorf("unknown shutdown policy %q", policy)
+}
+
+// ServerShutdown is sent to the game and the chat before the server stops
+type ServerShutdown struct {
+	Policy    string `json:"policy"`
+	GameRound uint   `json:"game_round"`
+}
+
+// Shutdown applies the shutdown policy to the round and stops the game, it waits for the
+// database and the NFT mint until ctx is done. Only the first call does something.
+func (g *Game) Shutdown(ctx context.Context) error {
+	err := g.callContext(ctx, func() error {
+		if g.closing {
+			return nil
+		}
+		g.closing = true
+		if g.GameStatus != GameRunning && g.GameStatus != GamePaused {
+			return nil
+		}
+		switch g.shutdownPolicy() {
+		case ShutdownVoid:
+			g.voidRound()
+		case ShutdownResume:
+			g.writeCheckpoint()
+		default:
+			g.endRound()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return g.waitJobs(ctx)
+}
+
+func (g *Game) shutdownPolicy() string {
+	if g.cfg.Shutdown.Policy == "" {
+		return ShutdownFinish
+	}
+	return g.cfg.Shutdown.Policy
+}
+
+// voidRound stops the round without a result
+func (g *Game) voidRound() {
+	g.GameStatus = GameStopped
+	if g.db == nil || g.dbGame == nil {
+		return
+	}
+	g.dbGame.Voided = true
+	g.dbGame.EndTime = time.Now()
+	dbGame := *g.dbGame
+	g.persistRound(func(id uint) {
+		dbGame.ID = id
+		if err := g.db.Game.Update(&dbGame); err != nil {
+			zap.L().Error("failed to void game", zap.Uint("game_id", dbGame.ID), zap.Error(err))
+		}
+		g.deleteCheckpoint(dbGame.ID)
+	})
+}
+
+// ShutdownTimeout is how long the shutdown waits for the rounds to be finalized
+func ShutdownTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultShutdownTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// shutdown tells the clients of the arena and finalizes its round
+func (a *Arena) shutdown(ctx context.Context) {
+	a.shutdownOnce.Do(func() {
+		msg := ServerShutdown{Policy: a.game.shutdownPolicy(), GameRound: a.game.GetGameID()}
+		a.app.GroupBroadcast(ctx, a.cfg.FrontendType, a.gameGroup, shutdownRoute, msg)
+		a.app.GroupBroadcast(ctx, a.cfg.FrontendType, a.chatGroup, shutdownRoute, msg)
+		if err := a.game.Shutdown(ctx); err != nil {
+			zap.L().Error("arena shutdown didn't finish", zap.String("arena", a.ID), zap.Error(err))
+		}
+	})
+}
+
+// Shutdown finalizes the rounds of every arena at the same time, it returns when they're done or ctx is
+func (as *Arenas) Shutdown(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, a := range as.list {
+		wg.Add(1)
+		go func(a *Arena) {
+			defer wg.Done()
+			a.shutdown(ctx)
+		}(a)
+	}
+	wg.Wait()
+}
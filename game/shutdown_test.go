@@ -0,0 +1,58 @@
+package game
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestShutdown(t *testing.T) {
+	for _, c := range []struct {
+		policy  string
+		running bool
+	}{
+		{"", false},
+		{ShutdownFinish, false},
+		{ShutdownVoid, false},
+		{ShutdownResume, true},
+	} {
+		g := newTestGame(&config.Config{FPS: 30, GameDuration: 10, Shutdown: config.ShutdownConfig{Policy: c.policy}})
+		stopped := 0
+		g.onGameStop = func(context.Context, GameStop) { stopped++ }
+		start := func() error { return g.StartRound("") }
+		command(g, start)
+		g.AddPlayer(1, BTC)
+		g.step()
+
+		shutdown := func() error { return g.Shutdown(context.Background()) }
+		if err := command(g, shutdown); err != nil {
+			t.Fatalf("%s: %v", c.policy, err)
+		}
+		if running := g.GameStatus == GameRunning; running != c.running {
+			t.Fatalf("%s: round still running %v", c.policy, running)
+		}
+		if want := map[bool]int{true: 1}[c.policy == "" || c.policy == ShutdownFinish]; stopped != want {
+			t.Fatalf("%s: onGameStop called %d times", c.policy, stopped)
+		}
+		tick := g.tick
+		g.step()
+		command(g, start)
+		if g.tick != tick || (g.GameStatus == GameRunning) != c.running {
+			t.Fatalf("%s: the game went on after the shutdown", c.policy)
+		}
+		if err := command(g, shutdown); err != nil || stopped > 1 {
+			t.Fatalf("%s: second shutdown %v, %d stops", c.policy, err, stopped)
+		}
+		// the votes after the shutdown are dropped, the queue never fills up
+		for i := 0; i < 2*cap(g.votes); i++ {
+			g.Vote(uint64(100+i), ETH)
+			if i%cap(g.votes) == 0 {
+				g.step()
+			}
+		}
+		if _, ok := g.Players.Load(uint64(100)); ok {
+			t.Fatalf("%s: vote played after the shutdown", c.policy)
+		}
+	}
+}
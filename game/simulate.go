@@ -0,0 +1,81 @@
+package game
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+// SimVote is a player joining a simulated round
+type SimVote struct {
+	Tick     uint32
+	PlayerID uint64
+	Camp     Camp
+}
+
+// SimResult is the outcome of a simulated round. Territory holds the share of the map of every camp,
+// in registry order, sampled every sampleTicks and once more at the end of the round.
+type SimResult struct {
+	Seed      int64
+	Ticks     uint32
+	Winner    Camp
+	Winners   []Camp
+	Draw      bool
+	Condition string
+	Territory [][]float64
+	Spawned   map[ItemType]int
+	PickedUp  map[ItemType]int
+}
+
+// Simulate plays a round with the votes and no server, database nor NFT around it. The same config,
+// seed and votes always give the same result.
+func Simulate(cfg *config.Config, seed int64, votes []SimVote, sampleTicks uint32) SimResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, nil, nil, func(context.Context) {}, func(context.Context, GameStop) {}, func(Camp, int32) {})
+	g.seedRound(seed)
+	g.GameStatus = GameRunning
+	g.roundTicks = uint32(cfg.GameDuration * g.tickRate())
+	if sampleTicks == 0 {
+		sampleTicks = uint32(g.tickRate())
+	}
+
+	votes = append([]SimVote(nil), votes...)
+	sort.SliceStable(votes, func(i, j int) bool { return votes[i].Tick < votes[j].Tick })
+	r := SimResult{Seed: seed}
+	for over := false; !over; {
+		for len(votes) > 0 && votes[0].Tick <= g.tick {
+			g.AddPlayer(votes[0].PlayerID, votes[0].Camp)
+			votes = votes[1:]
+		}
+		over = g.advance()
+		if g.tick%sampleTicks == 0 || over {
+			r.Territory = append(r.Territory, g.territory())
+		}
+	}
+
+	r.Ticks = g.tick
+	r.Winner, _ = g.GetWinner()
+	r.Winners = g.res.winners
+	r.Draw = g.Draw()
+	r.Condition = g.WinCondition()
+	r.Spawned = map[ItemType]int{}
+	for _, item := range g.replay.Items {
+		r.Spawned[item.Type]++
+	}
+	r.PickedUp = g.pickups
+	// the job goroutine ends with ctx, once what was queued is done
+	g.waitJobs(ctx)
+	return r
+}
+
+// territory is the share of the capturable cells held by each camp, in registry order
+func (g *Game) territory() []float64 {
+	results := g.CampResults()
+	shares := make([]float64, len(results))
+	for i, res := range results {
+		shares[i] = res.Share
+	}
+	return shares
+}
@@ -0,0 +1,23 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestSimulate(t *testing.T) {
+	cfg := &config.Config{FPS: 30, GameDuration: 20, ItemFrameChance: 20}
+	votes := []SimVote{{Tick: 300, PlayerID: 3, Camp: BNB}, {Tick: 0, PlayerID: 1, Camp: BTC}, {Tick: 0, PlayerID: 2, Camp: ETH}}
+	r := Simulate(cfg, 5, votes, 30)
+	if r.Ticks != 600 || r.Condition != WinByTimer || len(r.Territory) != 20 {
+		t.Fatalf("%d ticks, condition %q, %d samples", r.Ticks, r.Condition, len(r.Territory))
+	}
+	if len(r.Territory[0]) != len(Camps) || len(r.Spawned) == 0 {
+		t.Fatalf("territory %v, spawned %v", r.Territory[0], r.Spawned)
+	}
+	if again := Simulate(cfg, 5, votes, 30); !reflect.DeepEqual(r, again) {
+		t.Fatal("same seed and votes gave another round")
+	}
+}
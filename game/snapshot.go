@@ -0,0 +1,61 @@
+package game
+
+import (
+	"sort"
+
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+// Snapshot is a read-only copy of the game, published by the tick goroutine after every tick and
+// every command. Handlers read it instead of the live game, which only the tick goroutine touches.
+type Snapshot struct {
+	Game         *model.Game // copy of the round row, nil before the first round
+	GameID       uint
+	GameStatus   GameStatus
+	Phase        PhaseChange
+	AcceptsVotes bool
+	Duration     int // seconds of a round
+	Tick         uint32
+	CampVotes    map[Camp]int32
+	Results      []model.CampResult
+	Players      []uint64 // sorted, split balls left out
+	Restart      *Restart
+}
+
+// publish replaces the snapshot, only the tick goroutine calls it
+func (g *Game) publish() {
+	s := &Snapshot{
+		GameID:       g.gameID(),
+		GameStatus:   g.GameStatus,
+		Phase:        g.PhaseInfo(),
+		AcceptsVotes: !g.scheduled() || g.phase != PhaseResults,
+		Duration:     g.duration,
+		Tick:         g.tick,
+		CampVotes:    map[Camp]int32{},
+		Results:      g.CampResults(),
+		Restart:      g.restart,
+	}
+	if g.dbGame != nil {
+		dbGame := *g.dbGame
+		s.Game = &dbGame
+	}
+	g.campVotes.Range(func(key, value interface{}) bool {
+		if c, ok := key.(Camp); ok && value.(*int32) != nil {
+			s.CampVotes[c] = *(value.(*int32))
+		}
+		return true
+	})
+	g.Players.Range(func(key, value interface{}) bool {
+		if p, ok := value.(*Player); ok && p.ID < splitBallIDBase {
+			s.Players = append(s.Players, p.ID)
+		}
+		return true
+	})
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i] < s.Players[j] })
+	g.snapshot.Store(s)
+}
+
+// Snapshot returns the game as of the last tick or command, safe to call from any goroutine
+func (g *Game) Snapshot() *Snapshot {
+	return g.snapshot.Load().(*Snapshot)
+}
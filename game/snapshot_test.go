@@ -0,0 +1,67 @@
+package game
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+// run with -race, the handlers vote and read the game while it ticks
+func TestConcurrentVotes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, &config.Config{FPS: 30, TickRate: 120, GameDuration: 60, ItemFrameChance: 500}, nil, nil,
+		func(context.Context) {}, func(context.Context, GameStop) {}, func(camp Camp, votes int32) {})
+	frames := g.start()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-frames:
+			}
+		}
+	}()
+
+	const voters, votes = 4, 25
+	var wg sync.WaitGroup
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < votes; j++ {
+				if !g.AcceptsVotes() {
+					t.Error("votes refused")
+					return
+				}
+				if err := g.StartRound(""); err != nil {
+					t.Error(err)
+					return
+				}
+				g.Vote(uint64(i*votes+j+1), Camps[j%len(Camps)].Camp)
+				if g.GetGameID() != g.Snapshot().GameID {
+					t.Error("game id changed")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := g.Snapshot()
+		if len(s.Players) == voters*votes {
+			if s.GameStatus != GameRunning || s.Tick == 0 {
+				t.Fatalf("status %v at tick %d", s.GameStatus, s.Tick)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d votes played", len(s.Players), voters*votes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"sort"
+
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+const topContributors = 5
+
+// PlayerStats is what the balls of a player did during the round, split balls count for the player
+type PlayerStats struct {
+	PlayerID   uint64
+	Camp       Camp
+	Captured   int          // cells painted in the camp of the player, stolen ones included
+	StolenFrom map[Camp]int // cells taken from the other camps
+	Items      int
+	Bounces    int // on cells, walls, edges and other balls
+}
+
+func (s *PlayerStats) stolen() int {
+	n := 0
+	for _, v := range s.StolenFrom {
+		n += v
+	}
+	return n
+}
+
+// Contribution weighs a stolen cell twice, it both grows the camp and shrinks another one
+func (s *PlayerStats) Contribution() int {
+	return s.Captured + s.stolen()
+}
+
+// statsOf returns the stats of the player owning the ball
+func (g *Game) statsOf(p *Player) *PlayerStats {
+	id := p.ID
+	if p.owner != 0 {
+		id = p.owner
+	}
+	s, ok := g.stats[id]
+	if !ok {
+		s = &PlayerStats{PlayerID: id, Camp: p.Camp, StolenFrom: map[Camp]int{}}
+		g.stats[id] = s
+	}
+	return s
+}
+
+// captureBy paints the cell in the camp of the ball and credits its player
+func (g *Game) captureBy(p *Player, x, y int) {
+	prev, changed := g.captureCell(x, y, p.Camp)
+	if !changed {
+		return
+	}
+	g.emitCapture(p, x, y, prev)
+	s := g.statsOf(p)
+	s.Captured++
+	if prev != Empty {
+		s.StolenFrom[prev]++
+	}
+}
+
+func (g *Game) countBounce(p *Player) {
+	g.statsOf(p).Bounces++
+}
+
+func (g *Game) countItem(p *Player, t ItemType) {
+	g.statsOf(p).Items++
+	g.pickups[t]++
+}
+
+// PlayerStats returns the stats of every player of the round, best contribution first
+func (g *Game) PlayerStats() []model.PlayerGameStats {
+	stats := make([]model.PlayerGameStats, 0, len(g.stats))
+	for _, s := range g.stats {
+		from := make(map[uint8]int, len(s.StolenFrom))
+		for c, n := range s.StolenFrom {
+			from[uint8(c)] = n
+		}
+		stats = append(stats, model.PlayerGameStats{
+			GameID:       g.gameID(),
+			PlayerID:     s.PlayerID,
+			Camp:         uint8(s.Camp),
+			Captured:     s.Captured,
+			Stolen:       s.stolen(),
+			StolenFrom:   from,
+			Items:        s.Items,
+			Bounces:      s.Bounces,
+			Contribution: s.Contribution(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Contribution != stats[j].Contribution {
+			return stats[i].Contribution > stats[j].Contribution
+		}
+		return stats[i].PlayerID < stats[j].PlayerID
+	})
+	return stats
+}
+
+// MVPs returns the best contributor of each camp in registry order, the camps nobody played are left out
+func MVPs(stats []model.PlayerGameStats) []model.PlayerGameStats {
+	best := map[uint8]model.PlayerGameStats{}
+	for _, s := range stats {
+		// stats are sorted, the first one of a camp is its best
+		if _, ok := best[s.Camp]; !ok {
+			best[s.Camp] = s
+		}
+	}
+	var mvps []model.PlayerGameStats
+	for _, c := range Camps {
+		if s, ok := best[uint8(c.Camp)]; ok {
+			mvps = append(mvps, s)
+		}
+	}
+	return mvps
+}
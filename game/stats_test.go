@@ -0,0 +1,49 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func TestPlayerStats(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30})
+	g.GameStatus = GameRunning
+	btc := g.AddPlayer(1, BTC)
+	eth := g.AddPlayer(2, ETH)
+	g.AddPlayer(3, ETH)
+
+	// the bomb of the BTC ball lands in its own camp, every cell it paints was empty or BTC
+	PaintBomb.Effect.Apply(g, btc, 0, 0)
+	s := g.stats[1]
+	if s == nil || s.Captured == 0 || len(s.StolenFrom) != 0 {
+		t.Fatalf("BTC bomb stats %+v", s)
+	}
+	// the ETH ball steals them with a bomb at the same place
+	placeBall(eth, btc.playerObj.X, btc.playerObj.Y, 0, 0)
+	PaintBomb.Effect.Apply(g, eth, 0, 0)
+	if e := g.stats[2]; e.StolenFrom[BTC] < s.Captured || e.StolenFrom[BTC] != e.Captured {
+		t.Fatalf("ETH captured %d and stole %d cells of BTC, BTC painted %d", e.Captured, e.StolenFrom[BTC], s.Captured)
+	}
+
+	// a split ball works for its player
+	Split.Effect.Apply(g, eth, 0, 0)
+	v, _ := g.Players.Load(uint64(splitBallIDBase + 1))
+	split := v.(*Player)
+	placeBall(split, btc.playerObj.X, btc.playerObj.Y, 0, 0)
+	PaintBomb.Effect.Apply(g, btc, 0, 0)
+	before := g.stats[2].Captured
+	PaintBomb.Effect.Apply(g, split, 0, 0)
+	if g.stats[2].Captured <= before || g.stats[splitBallIDBase+1] != nil {
+		t.Fatal("split ball captures not credited to its player")
+	}
+
+	stats := g.PlayerStats()
+	if len(stats) != 2 || stats[0].PlayerID != 2 || stats[0].Contribution != stats[0].Captured+stats[0].Stolen {
+		t.Fatalf("player stats %+v", stats)
+	}
+	mvps := MVPs(stats)
+	if len(mvps) != 2 || mvps[0].PlayerID != 1 || mvps[1].PlayerID != 2 {
+		t.Fatalf("mvps %+v", mvps)
+	}
+}
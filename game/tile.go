@@ -0,0 +1,95 @@
+package game
+
+import (
+	"fmt"
+)
+
+// Tile is the static kind of a cell, it's set by the map and never changes during a round
+type Tile uint8
+
+const (
+	TileNormal Tile = iota
+	TileWall        // never capturable, balls always bounce on it
+	TileHole        // balls falling in come back at the center of their camp
+	TileBonus       // capturable, counts as several cells when the round ends
+
+	WallTag  = "WALL"
+	HoleTag  = "HOLE"
+	BonusTag = "BONUS"
+
+	defaultBonusValue = 3
+)
+
+// characters of the map grid, one string per row
+var tileChars = map[rune]Tile{
+	'.': TileNormal,
+	'#': TileWall,
+	'o': TileHole,
+	'+': TileBonus,
+}
+
+func parseTileGrid(grid []string) (row, column int, tiles []Tile, err error) {
+	row = len(grid)
+	for y, line := range grid {
+		runes := []rune(line)
+		if y == 0 {
+			column = len(runes)
+		} else if len(runes) != column {
+			return 0, 0, nil, fmt.Errorf("map grid row %d has %d cells, want %d", y, len(runes), column)
+		}
+		for x, c := range runes {
+			t, ok := tileChars[c]
+			if !ok {
+				return 0, 0, nil, fmt.Errorf("unknown tile %q at %d,%d", c, x, y)
+			}
+			tiles = append(tiles, t)
+		}
+	}
+	return row, column, tiles, nil
+}
+
+// Tile returns the tile of the cell at column x and row y
+func (l *MapLayout) Tile(x, y int) Tile {
+	if l.Tiles == nil {
+		return TileNormal
+	}
+	return l.Tiles[y*l.Column+x]
+}
+
+func (l *MapLayout) capturable(x, y int) bool {
+	t := l.Tile(x, y)
+	return t == TileNormal || t == TileBonus
+}
+
+// CellValue is what the cell at index i counts for when the round ends
+func (l *MapLayout) CellValue(i int) int {
+	if l.Tiles != nil && l.Tiles[i] == TileBonus {
+		return l.BonusValue
+	}
+	return 1
+}
+
+// SerializeTiles packs the tiles 4 bits per cell, the same way the cells are packed in frames
+func (l *MapLayout) SerializeTiles() []byte {
+	n := l.Row * l.Column
+	return packNibbles(n, func(i int) byte {
+		if l.Tiles == nil {
+			return byte(TileNormal)
+		}
+		return byte(l.Tiles[i])
+	})
+}
+
+func packNibbles(n int, get func(i int) byte) []byte {
+	res := make([]byte, (n*sizeOfCellStateBits+7)/8)
+	offset := 0
+	for i := 0; i < n; i += 2 {
+		b := (get(i) << 4) & campMaskLeft
+		if i+1 < n {
+			b = b | (get(i+1) & campMaskRight)
+		}
+		res[offset] = b
+		offset++
+	}
+	return res
+}
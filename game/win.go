@@ -0,0 +1,244 @@
+package game
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ZecreyGaming/BlockChainWar/model"
+)
+
+const (
+	WinByTimer     = "timer"      // the camp with the most cells when the time is up
+	WinByTerritory = "territory"  // a camp held the configured share of the map
+	WinByLastCamp  = "last_camp"  // every other camp lost all its cells
+	WinBySubRounds = "sub_rounds" // the camp winning the most sub-rounds
+
+	TieShared   = "shared"   // the tied camps share a draw
+	TieVotes    = "votes"    // the tied camp with the most votes wins
+	TieEarliest = "earliest" // the tied camp that reached its score first wins
+)
+
+func validTieBreak(rule string) error {
+	switch rule {
+	case "", TieShared, TieVotes, TieEarliest:
+		return nil
+	}
+	return fmt.Errorf("unknown tie_break %q", rule)
+}
+
+// advance runs one tick of a running round, true when the round is over
+func (g *Game) advance() bool {
+	g.Update()
+	g.scoreSubRound()
+	if g.checkEarlyWin() {
+		return true
+	}
+	return g.tick >= g.roundTicks
+}
+
+// campScores is what each camp holds, bonus cells counted with their value
+func (g *Game) campScores() map[Camp]int {
+	score := make(map[Camp]int)
+	for i, v := range g.Map.Cells {
+		if v != Empty {
+			score[v] += g.layout.CellValue(i)
+		}
+	}
+	return score
+}
+
+// checkEarlyWin ends the round before the timer when a camp holds enough of the map or is the only one left
+func (g *Game) checkEarlyWin() bool {
+	win := g.cfg.Win
+	if win.Territory <= 0 && !win.LastCamp {
+		return false
+	}
+	cells := make(map[Camp]int)
+	capturable := 0
+	for i, v := range g.Map.Cells {
+		if g.cells[i] == nil {
+			continue
+		}
+		capturable++
+		if v != Empty {
+			cells[v]++
+		}
+	}
+	if win.Territory > 0 {
+		for _, c := range Camps {
+			if cells[c.Camp]*100 >= win.Territory*capturable {
+				g.res = &res{winner: c.Camp, winners: []Camp{c.Camp}, score: g.campScores()[c.Camp], condition: WinByTerritory}
+				return true
+			}
+		}
+	}
+	if win.LastCamp && len(cells) == 1 {
+		for camp := range cells {
+			g.res = &res{winner: camp, winners: []Camp{camp}, score: g.campScores()[camp], condition: WinByLastCamp}
+		}
+		return true
+	}
+	return false
+}
+
+func (g *Game) subRoundTicks() uint32 {
+	if g.cfg.Win.SubRounds <= 1 {
+		return 0
+	}
+	return g.roundTicks / uint32(g.cfg.Win.SubRounds)
+}
+
+// scoreSubRound gives a point to the camp leading at the end of a sub-round and paints the map back
+// to its starting state for the next one. A sub-round still tied after the tie_break rule is won by
+// nobody, it's recorded as Empty.
+func (g *Game) scoreSubRound() {
+	n := g.subRoundTicks()
+	if n == 0 || g.tick%n != 0 || len(g.subRoundWins) >= g.cfg.Win.SubRounds {
+		return
+	}
+	tied, _ := leaders(g.campScores())
+	winner := Empty
+	if winners := g.breakTie(tied, g.reachedScoreAt); len(winners) == 1 {
+		winner = winners[0]
+	}
+	g.subRoundWins = append(g.subRoundWins, winner)
+	if len(g.subRoundWins) == g.cfg.Win.SubRounds {
+		return
+	}
+	for i, obj := range g.cells {
+		if obj != nil {
+			x, y := i%g.Map.Column, i/g.Map.Column
+			g.captureCell(x, y, g.layout.initCamp(x, y))
+		}
+	}
+	g.resetScores()
+}
+
+// subRoundWinners are the camps that won the most sub-rounds after the tie_break rule, several
+// share a draw and none means no sub-round had a winner
+func (g *Game) subRoundWinners() ([]Camp, int) {
+	wins := make(map[Camp]int)
+	reached := make(map[Camp]uint32) // sub-round a camp got its last win in
+	for i, c := range g.subRoundWins {
+		if c != Empty {
+			wins[c]++
+			reached[c] = uint32(i)
+		}
+	}
+	tied, maxWins := leaders(wins)
+	return g.breakTie(tied, func(c Camp) uint32 { return reached[c] }), maxWins
+}
+
+// resetScores counts the scores of the map as it is, as if the camps reached them now
+func (g *Game) resetScores() {
+	g.scores = g.campScores()
+	g.scoreReached = map[Camp][]uint32{}
+	for c := range g.scores {
+		g.reachScore(c)
+	}
+}
+
+// reachScore records the tick the camp first reached each score up to the one it holds now
+func (g *Game) reachScore(c Camp) {
+	for len(g.scoreReached[c]) < g.scores[c] {
+		g.scoreReached[c] = append(g.scoreReached[c], g.tick)
+	}
+}
+
+// reachedScoreAt is the first tick the camp reached the score it holds now
+func (g *Game) reachedScoreAt(c Camp) uint32 {
+	if s := g.scores[c]; s > 0 && s <= len(g.scoreReached[c]) {
+		return g.scoreReached[c][s-1]
+	}
+	return 0
+}
+
+// leaders returns the camps sharing the highest score in registry order, none when nobody scored
+func leaders(score map[Camp]int) ([]Camp, int) {
+	var tied []Camp
+	maxScore := 0
+	for _, c := range Camps {
+		switch s := score[c.Camp]; {
+		case s > maxScore:
+			tied, maxScore = []Camp{c.Camp}, s
+		case s == maxScore && s > 0:
+			tied = append(tied, c.Camp)
+		}
+	}
+	return tied, maxScore
+}
+
+// breakTie applies the tie_break rule, the camps still tied after it share the win
+func (g *Game) breakTie(tied []Camp, earliest func(Camp) uint32) []Camp {
+	if len(tied) < 2 {
+		return tied
+	}
+	var key func(c Camp) int64 // higher wins
+	switch g.cfg.Win.TieBreak {
+	case TieVotes:
+		key = func(c Camp) int64 { return int64(g.campVoteCount(c)) }
+	case TieEarliest:
+		key = func(c Camp) int64 { return -int64(earliest(c)) }
+	default:
+		return tied
+	}
+	var best []Camp
+	for _, c := range tied {
+		if len(best) == 0 || key(c) > key(best[0]) {
+			best = []Camp{c}
+		} else if key(c) == key(best[0]) {
+			best = append(best, c)
+		}
+	}
+	return best
+}
+
+func (g *Game) campVoteCount(camp Camp) int32 {
+	if v, ok := g.campVotes.Load(camp); ok {
+		if n, ok := v.(*int32); ok && n != nil {
+			return atomic.LoadInt32(n)
+		}
+	}
+	return 0
+}
+
+// CampResults is the cell count and share of every camp, the winners are flagged once the round is decided
+func (g *Game) CampResults() []model.CampResult {
+	cells := make(map[Camp]int)
+	capturable := 0
+	for i, v := range g.Map.Cells {
+		if g.cells[i] == nil {
+			continue
+		}
+		capturable++
+		cells[v]++
+	}
+	scores := g.campScores()
+	winners := map[Camp]bool{}
+	if g.res != nil {
+		for _, c := range g.res.winners {
+			winners[c] = true
+		}
+	}
+	var gameID uint
+	if g.dbGame != nil {
+		gameID = g.dbGame.ID
+	}
+	results := make([]model.CampResult, 0, len(Camps))
+	for _, c := range Camps {
+		share := 0.0
+		if capturable > 0 {
+			share = float64(cells[c.Camp]) / float64(capturable)
+		}
+		results = append(results, model.CampResult{
+			GameID: gameID,
+			Camp:   uint8(c.Camp),
+			Cells:  cells[c.Camp],
+			Score:  scores[c.Camp],
+			Share:  share,
+			Votes:  g.campVoteCount(c.Camp),
+			Winner: winners[c.Camp],
+		})
+	}
+	return results
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/ZecreyGaming/BlockChainWar/config"
+)
+
+func paint(g *Game, camp Camp, n int) {
+	for i := 0; i < len(g.cells) && n > 0; i++ {
+		if g.Map.Cells[i] != camp {
+			g.captureCell(i%g.Map.Column, i/g.Map.Column, camp)
+			n--
+		}
+	}
+}
+
+func TestEarlyWin(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{Territory: 50}})
+	g.GameStatus = GameRunning
+	g.roundTicks = 1000
+	if g.advance() {
+		t.Fatal("round over at the first tick")
+	}
+	paint(g, ETH, len(g.cells)/2)
+	if !g.advance() || g.WinCondition() != WinByTerritory {
+		t.Fatalf("territory not detected, condition %q", g.WinCondition())
+	}
+	if winner, _ := g.GetWinner(); winner != ETH {
+		t.Fatalf("winner is %d", winner)
+	}
+
+	g = newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{LastCamp: true}})
+	g.GameStatus = GameRunning
+	g.roundTicks = 1000
+	for i, c := range g.Map.Cells {
+		if c != Empty && c != AVAX {
+			g.captureCell(i%g.Map.Column, i/g.Map.Column, AVAX)
+		}
+	}
+	if !g.advance() || g.WinCondition() != WinByLastCamp {
+		t.Fatalf("last camp not detected, condition %q", g.WinCondition())
+	}
+	if winner, _ := g.GetWinner(); winner != AVAX {
+		t.Fatalf("winner is %d", winner)
+	}
+}
+
+func TestSubRounds(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{SubRounds: 3}})
+	g.GameStatus = GameRunning
+	g.roundTicks = 30
+	// BNB leads the first two sub-rounds, ETH the last one
+	for _, leader := range []Camp{BNB, BNB, ETH} {
+		paint(g, leader, 20)
+		for i := 0; i < 10; i++ {
+			if g.advance() != (g.tick == 30) {
+				t.Fatalf("round over at tick %d", g.tick)
+			}
+		}
+		if leader == BNB && g.Map.Cells[0] == BNB {
+			t.Fatal("map not reset after the sub-round")
+		}
+	}
+	if winner, wins := g.GetWinner(); winner != BNB || wins != 2 || g.WinCondition() != WinBySubRounds {
+		t.Fatalf("winner %d with %d sub-rounds by %q", winner, wins, g.WinCondition())
+	}
+
+	// an untouched sub-round has no winner and a tied tally is a draw
+	g = newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{SubRounds: 3}})
+	g.GameStatus = GameRunning
+	g.roundTicks = 30
+	for _, leader := range []Camp{Empty, ETH, BNB} {
+		if leader != Empty {
+			paint(g, leader, 20)
+		}
+		for i := 0; i < 10; i++ {
+			g.advance()
+		}
+	}
+	if g.subRoundWins[0] != Empty {
+		t.Fatalf("untouched sub-round won by %d", g.subRoundWins[0])
+	}
+	if winner, wins := g.GetWinner(); winner != Empty || wins != 1 || !g.Draw() || len(g.res.winners) != 2 {
+		t.Fatalf("tied sub-rounds won by %d with %d, winners %v", winner, wins, g.res.winners)
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	g := newTestGame(&config.Config{FPS: 30})
+	if winner, _ := g.GetWinner(); winner != Empty || !g.Draw() || len(g.res.winners) != len(Camps) {
+		t.Fatalf("untouched map won by %d, winners %v", winner, g.res.winners)
+	}
+	results := g.CampResults()
+	if len(results) != len(Camps) || results[0].Cells != 5 || results[0].Share <= 0 {
+		t.Fatalf("results %+v", results)
+	}
+
+	for _, c := range []struct {
+		rule   string
+		winner Camp
+	}{
+		{TieShared, Empty},
+		{TieVotes, ETH},
+		{TieEarliest, BTC},
+	} {
+		g := newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{TieBreak: c.rule}})
+		g.AddPlayer(1, BTC)
+		g.AddPlayer(2, ETH)
+		g.AddPlayer(3, ETH)
+		g.tick = 10
+		g.captureCell(0, 0, BTC)
+		g.tick = 20
+		g.captureCell(1, 0, ETH)
+		winner, score := g.GetWinner()
+		if winner != c.winner || score != 6 {
+			t.Fatalf("%s: winner %d with %d", c.rule, winner, score)
+		}
+		if c.rule == TieShared && (!g.Draw() || len(g.res.winners) != 2) {
+			t.Fatalf("shared win between %v", g.res.winners)
+		}
+	}
+
+	// ETH reaches the final score first, loses a cell and gets it back after BTC reached it
+	g = newTestGame(&config.Config{FPS: 30, Win: config.WinConfig{TieBreak: TieEarliest}})
+	if scores := g.campScores(); scores[BTC] != scores[ETH] {
+		t.Fatalf("BTC and ETH start at %d and %d", scores[BTC], scores[ETH])
+	}
+	var avax []int
+	for i, camp := range g.Map.Cells {
+		if camp == AVAX {
+			avax = append(avax, i)
+		}
+	}
+	capture := func(tick uint32, cell int, camp Camp) {
+		g.tick = tick
+		g.captureCell(cell%g.Map.Column, cell/g.Map.Column, camp)
+	}
+	capture(10, avax[0], BTC)
+	capture(20, avax[1], ETH)
+	capture(30, avax[2], ETH)
+	capture(40, avax[3], BTC)
+	capture(50, avax[2], AVAX)
+	capture(60, avax[2], ETH)
+	if winner, _ := g.GetWinner(); winner != ETH {
+		t.Fatalf("earliest tie break won by %d, want ETH", winner)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	sdk "github.com/ZecreyGaming/BlockChainWar/game/cronjob/zecreyface"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ZecreyGaming/BlockChainWar/admin"
 	"github.com/ZecreyGaming/BlockChainWar/chat"
 	cfg "github.com/ZecreyGaming/BlockChainWar/config"
 	"github.com/ZecreyGaming/BlockChainWar/db"
@@ -21,12 +23,16 @@ import (
 	"github.com/topfreegames/pitaya/v2/logger"
 	"github.com/topfreegames/pitaya/v2/logger/interfaces"
 	logruswrapper "github.com/topfreegames/pitaya/v2/logger/logrus"
+	"github.com/topfreegames/pitaya/v2/session"
 )
 
 var (
 	configPath = flag.String("config", "./config/config.json", "Path to config file")
 )
 
+// shutdownDelay leaves time for the last messages to reach the clients before the sockets close
+const shutdownDelay = time.Second
+
 func main() {
 	flag.Parse()
 	cfg := cfg.Read(*configPath)
@@ -35,6 +41,8 @@ func main() {
 	builder.AddAcceptor(acceptor.NewWSAcceptor(":3250"))
 	builder.Groups = groups.NewMemoryGroupService(*config.NewDefaultMemoryGroupConfig())
 	builder.Serializer = game.NewSerializer()
+	pool := &drainingPool{SessionPool: builder.SessionPool}
+	builder.SessionPool = pool
 	app := builder.Build()
 
 	defer app.Shutdown()
@@ -51,20 +59,43 @@ func main() {
 		panic(err)
 	}
 	// register game and chat
-	g := game.RegistRoom(app, database, cfg, sdkClient)
-	chat.RegistRoom(app, database, cfg, g)
+	arenas := game.RegistRoom(app, database, cfg, sdkClient)
+	chat.RegistRoom(app, database, cfg, arenas)
 
 	log.SetFlags(log.LstdFlags | log.Llongfile)
 
 	http.Handle("/web/", http.StripPrefix("/web/", http.FileServer(http.Dir("web"))))
 	http.Handle("/demoweb/", http.StripPrefix("/demoweb/", http.FileServer(http.Dir("demoweb"))))
+	http.Handle(admin.Prefix, admin.NewServer(cfg, database, app, arenas, sdkClient))
 
 	go http.ListenAndServe(":3251", nil) //http://127.0.0.1:3251/web/
 
+	pool.drain = func() {
+		log.Printf("shutting down, finalizing the rounds")
+		ctx, cancel := context.WithTimeout(context.Background(), game.ShutdownTimeout(cfg.Shutdown.Timeout))
+		arenas.Shutdown(ctx)
+		cancel()
+		time.Sleep(shutdownDelay)
+	}
+
 	fmt.Printf("Starting server at 0.0.0.0:%d...\n", 3250)
 	app.Start()
 }
 
+// drainingPool finalizes the rounds when pitaya shuts down, on a signal or app.Shutdown, before it closes
+// the sessions, so the clients still get the last messages
+type drainingPool struct {
+	session.SessionPool
+	drain func()
+}
+
+func (p *drainingPool) CloseAll() {
+	if p.drain != nil {
+		p.drain()
+	}
+	p.SessionPool.CloseAll()
+}
+
 func configApp() config.BuilderConfig {
 	conf := config.NewDefaultBuilderConfig()
 	conf.Pitaya.Heartbeat.Interval = time.Duration(3 * time.Second)
@@ -12,6 +12,8 @@ type Player struct {
 	L2publicKey string `json:"l2public_key"`
 	Score       int    `json:"score"`
 	Thumbnail   string `json:"thumbnail"`
+	Banned      bool   `json:"banned"`
+	Bot         bool   `json:"bot"` // played by the server, never rewarded nor ranked
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
@@ -31,20 +33,88 @@ type Camp struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 	Name      string         `gorm:"uniqueIndex" json:"name"`
 	ShortName string         `json:"short_name"`
+	Color     string         `json:"color"`
 	Icon      string         `json:"icon"`
 	Score     int            `json:"score"`
 }
 
 type Game struct {
 	gorm.Model
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	WinnerID  uint8     `json:"winner_id"`
-	Winner    Camp      `gorm:"foreignKey:WinnerID" json:"winner"`
+	ArenaID      string    `gorm:"index" json:"arena_id"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	WinnerID     uint8     `json:"winner_id"`
+	Winner       Camp      `gorm:"foreignKey:WinnerID" json:"winner"`
+	WinCondition string    `json:"win_condition"`
+	Draw         bool      `json:"draw"` // several camps shared the win, WinnerID is 0
+	Seed         int64     `json:"seed"`
+	Voided       bool      `json:"voided"` // the round was cut by a restart and never scored
+}
+
+// PlayerGameStats is what the balls of a player did in a round, Contribution is the cells captured plus the
+// cells stolen from the other camps
+type PlayerGameStats struct {
+	GameID       uint          `gorm:"primarykey;autoIncrement:false" json:"game_id"`
+	PlayerID     uint64        `gorm:"primarykey;autoIncrement:false" json:"player_id"`
+	Camp         uint8         `json:"camp"`
+	Captured     int           `json:"captured"`
+	Stolen       int           `json:"stolen"`
+	StolenFrom   map[uint8]int `gorm:"type:text;serializer:json" json:"stolen_from"` // camp -> cells
+	Items        int           `json:"items"`
+	Bounces      int           `json:"bounces"`
+	Contribution int           `gorm:"index" json:"contribution"`
+}
+
+// PlayerContribution is the contribution of a player summed over the rounds
+type PlayerContribution struct {
+	PlayerID     uint64 `json:"player_id"`
+	PlayerName   string `json:"player_name"`
+	Contribution int    `json:"contribution"`
+	Games        int    `json:"games"`
+}
+
+// Checkpoint is the state of the round running in an arena, saved regularly so that a restart can resume it
+type Checkpoint struct {
+	ArenaID   string `gorm:"primarykey" json:"arena_id"`
+	GameID    uint   `json:"game_id"`
+	Tick      uint32 `json:"tick"`
+	Data      []byte `json:"data"`
+	UpdatedAt time.Time
+}
+
+// CheckpointReplay is a part of the replay of a checkpointed round, every checkpoint adds the votes
+// and items recorded since the previous one
+type CheckpointReplay struct {
+	ArenaID string `gorm:"primarykey" json:"arena_id"`
+	GameID  uint   `gorm:"primarykey" json:"game_id"`
+	Seq     uint32 `gorm:"primarykey" json:"seq"`
+	Data    []byte `json:"data"`
+}
+
+// CampResult is where a camp ended a round
+type CampResult struct {
+	GameID uint    `gorm:"primarykey;autoIncrement:false" json:"game_id"`
+	Camp   uint8   `gorm:"primarykey;autoIncrement:false" json:"camp"`
+	Cells  int     `json:"cells"`
+	Score  int     `json:"score"` // cells with bonus tiles counted at their value
+	Share  float64 `json:"share"` // of the cells that can be captured, 0 to 1
+	Votes  int32   `json:"votes"`
+	Winner bool    `json:"winner"`
+}
+
+type Replay struct {
+	gorm.Model
+	GameID  uint   `gorm:"uniqueIndex" json:"game_id"`
+	ArenaID string `json:"arena_id"`
+	Seed    int64  `json:"seed"`
+	Ticks   uint32 `json:"ticks"`
+	Data    []byte `json:"-"`
+	Config  []byte `json:"-"` // json of the config the round was played with, empty for the older replays
 }
 
 type Message struct {
 	gorm.Model
+	ArenaID       string `gorm:"index" json:"arena_id"`
 	Message       string `json:"message"`
 	SignedMessage string `json:"signed_message"`
 	PlayerID      uint64 `json:"player_id"`
@@ -52,26 +122,28 @@ type Message struct {
 }
 
 const (
-	Empty = iota
-	BTC
-	ETH
-	BNB
-	AVAX
-	MATIC
+	RewardPending = "pending"
+	RewardMinted  = "minted"
+	RewardFailed  = "failed"
 )
 
-var (
-	BTCCamp   = Camp{ID: uint8(BTC), Name: "Bitcoin", ShortName: "BTC", Icon: "https://example.com/red.png"}
-	ETHCamp   = Camp{ID: uint8(ETH), Name: "Ethereum", ShortName: "ETH", Icon: "https://example.com/blue.png"}
-	BNBCamp   = Camp{ID: uint8(BNB), Name: "Binance", ShortName: "BNB", Icon: "https://example.com/green.png"}
-	AVAXCamp  = Camp{ID: uint8(AVAX), Name: "Avalanche", ShortName: "AVAX", Icon: "https://example.com/yellow.png"}
-	MATICCamp = Camp{ID: uint8(MATIC), Name: "Polygon", ShortName: "MATIC", Icon: "https://example.com/purple.png"}
-
-	Camps = []Camp{
-		BTCCamp,
-		ETHCamp,
-		BNBCamp,
-		AVAXCamp,
-		MATICCamp,
-	}
-)
+// Reward is the NFT minted for the winner of a round, failed ones can be retried from the admin API
+type Reward struct {
+	gorm.Model
+	GameID      uint   `gorm:"index" json:"game_id"`
+	ArenaID     string `json:"arena_id"`
+	AccountName string `json:"account_name"`
+	Status      string `gorm:"index" json:"status"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error"`
+}
+
+// AuditLog records an action taken through the admin API
+type AuditLog struct {
+	gorm.Model
+	Actor   string `json:"actor"`
+	Action  string `gorm:"index" json:"action"`
+	ArenaID string `json:"arena_id"`
+	Params  string `json:"params"`
+	Error   string `json:"error"`
+}